@@ -0,0 +1,192 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	accessLog      io.Writer
+	accessLogMutex sync.Mutex
+)
+
+// SetAccessLog sets a writer to which a Router writes a line of json for every request served,
+// with the method, path, status, bytes written, duration in milliseconds, client IP and
+// request ID of the request, along with the fields set via LogField.
+func SetAccessLog(w io.Writer) {
+	accessLog = w
+}
+
+// accessLogEntry is a line of the access log.
+type accessLogEntry struct {
+	Time       time.Time              `json:"time"`
+	Method     string                 `json:"method"`
+	Path       string                 `json:"path"`
+	Status     int                    `json:"status"`
+	Bytes      int64                  `json:"bytes"`
+	DurationMS float64                `json:"duration_ms"`
+	ClientIP   string                 `json:"client_ip"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// logFields are the fields of a request set via LogField. They are shared by every context
+// derived from the request's, so that fields set after the request's context is replaced,
+// e.g. by a filter, still reach the access log.
+type logFields struct {
+	mutex  sync.Mutex
+	values map[string]interface{}
+}
+
+// withLogFields returns a shallow copy of the request, whose context holds an empty set of fields.
+func withLogFields(r *http.Request) (*http.Request, *logFields) {
+	fields := &logFields{values: make(map[string]interface{})}
+
+	return r.WithContext(context.WithValue(r.Context(), logFieldsKey, fields)), fields
+}
+
+// LogField sets a field of the request, e.g. the ID of the signed in user or the tenant, included
+// in the "fields" object of the request's access log line, as set via SetAccessLog. Fields can be
+// set at any point while the request is served, a field set again replacing its value.
+func (c *Controller) LogField(key string, value interface{}) {
+	fields, ok := c.Request.Context().Value(logFieldsKey).(*logFields)
+
+	if !ok {
+		c.Request, fields = withLogFields(c.Request)
+	}
+
+	fields.mutex.Lock()
+	defer fields.mutex.Unlock()
+
+	fields.values[key] = value
+}
+
+// LogFields returns a copy of the fields of the request set via LogField.
+func (c *Controller) LogFields() map[string]interface{} {
+	fields, ok := c.Request.Context().Value(logFieldsKey).(*logFields)
+
+	if !ok {
+		return nil
+	}
+
+	return fields.copy()
+}
+
+// copy returns a copy of the values of the fields, or nil if none are set.
+func (f *logFields) copy() map[string]interface{} {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.values) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(f.values))
+
+	for k, v := range f.values {
+		copied[k] = v
+	}
+
+	return copied
+}
+
+// logAccess writes a line to the access log for a served request.
+func logAccess(rec *responseRecorder, r *http.Request, fields *logFields, duration time.Duration) {
+	entry := accessLogEntry{
+		Time:       time.Now().UTC(),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     rec.Status(),
+		Bytes:      rec.bytes,
+		DurationMS: float64(duration) / float64(time.Millisecond),
+		ClientIP:   (&Controller{Request: r}).ClientIP(),
+		RequestID:  rec.Header().Get("X-Request-ID"),
+		Fields:     fields.copy(),
+	}
+
+	b, err := json.Marshal(entry)
+
+	if err != nil {
+		return
+	}
+
+	accessLogMutex.Lock()
+	defer accessLogMutex.Unlock()
+
+	accessLog.Write(append(b, '\n'))
+}
+
+// responseRecorder is an http.ResponseWriter which records the status and number of bytes written,
+// and the body written if capture is set.
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	capture *bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+
+	w.bytes += int64(n)
+
+	if w.capture != nil {
+		w.capture.Write(b[:n])
+	}
+
+	return n, err
+}
+
+// Status returns the status written, http.StatusOK if none was written explicitly.
+func (w *responseRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Flush flushes the underlying ResponseWriter, if it supports flushing.
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by http.ResponseController.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
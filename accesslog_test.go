@@ -0,0 +1,124 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	SetAccessLog(&buf)
+
+	defer SetAccessLog(nil)
+
+	rt := NewRouter()
+
+	rt.Handle("POST", "/posts", "post", func(c *Controller) {
+		c.WriteHeader(http.StatusCreated)
+		c.TextContent("created")
+	})
+
+	r, _ := http.NewRequest("POST", "/posts?draft=1", nil)
+	r.RemoteAddr = "203.0.113.7:5000"
+
+	w := httptest.NewRecorder()
+
+	rt.ServeHTTP(w, r)
+
+	var entry map[string]interface{}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log was '%s': %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"method":     "POST",
+		"path":       "/posts",
+		"status":     float64(http.StatusCreated),
+		"bytes":      float64(len("created")),
+		"client_ip":  "203.0.113.7",
+		"request_id": w.Header().Get("X-Request-ID"),
+	}
+
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("%s was %v, expected %v", k, entry[k], v)
+		}
+	}
+
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("Expected a duration, log was '%s'", buf.String())
+	}
+}
+
+func TestLogField(t *testing.T) {
+	var buf bytes.Buffer
+
+	SetAccessLog(&buf)
+
+	defer SetAccessLog(nil)
+
+	rt := NewRouter()
+
+	rt.Before(func(c *Controller) bool {
+		c.SetUser("matt")
+		c.LogField("tenant", "acme")
+
+		return true
+	})
+
+	rt.Handle("GET", "/orders", "order", func(c *Controller) {
+		c.TextContent("orders")
+		c.LogField("user_id", 42)
+		c.LogField("tenant", "acme-eu")
+	})
+
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+
+	var entry struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log was '%s': %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{"tenant": "acme-eu", "user_id": float64(42)}
+
+	if !reflect.DeepEqual(entry.Fields, expected) {
+		t.Errorf("Fields were %v, expected %v", entry.Fields, expected)
+	}
+
+	c := mockController("order")
+
+	if c.LogFields() != nil {
+		t.Errorf("Expected no fields before any are set")
+	}
+
+	c.LogField("user_id", 7)
+
+	if fields := c.LogFields(); fields["user_id"] != 7 {
+		t.Errorf("Fields were %v, expected user_id 7", fields)
+	}
+}
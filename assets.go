@@ -0,0 +1,89 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// assetFS is the file system static assets are read from, when inlined within views.
+var assetFS fs.FS
+
+// SetAssetRoot sets the directory static assets are read from by the "inline" view template function.
+func SetAssetRoot(dir string) {
+	assetFS = os.DirFS(dir)
+}
+
+var (
+	assetManifest      map[string]string
+	assetManifestMutex sync.RWMutex
+)
+
+// SetAssetManifest sets the manifest of fingerprinted static assets, mapping logical names to the
+// URLs of their hashed files, e.g. "app.css" to "/static/app.3f2a9c.css", as produced by a build
+// pipeline. The "asset" view template function looks up names within it.
+func SetAssetManifest(manifest map[string]string) {
+	copied := make(map[string]string, len(manifest))
+
+	for name, url := range manifest {
+		copied[name] = url
+	}
+
+	assetManifestMutex.Lock()
+	assetManifest = copied
+	assetManifestMutex.Unlock()
+}
+
+// assetURL returns the fingerprinted URL of the named asset, as set via SetAssetManifest, or the
+// name itself if the manifest has no entry for it.
+func assetURL(name string) string {
+	assetManifestMutex.RLock()
+	defer assetManifestMutex.RUnlock()
+
+	if url, ok := assetManifest[name]; ok {
+		return url
+	}
+
+	return name
+}
+
+// inlineAsset returns the contents of a css file within the asset root directory. Names
+// referring outside of the directory are rejected. If the file cannot be read, a css comment
+// noting so is returned, so that the problem is visible without failing the render.
+func inlineAsset(name string) template.CSS {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	unavailable := template.CSS(fmt.Sprintf("/* inline: %q is not available */", strings.Replace(name, "*/", "", -1)))
+
+	if assetFS == nil || !fs.ValidPath(name) {
+		return unavailable
+	}
+
+	b, err := fs.ReadFile(assetFS, name)
+
+	if err != nil {
+		return unavailable
+	}
+
+	return template.CSS(b)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInlineAsset(t *testing.T) {
+	assets, err := ioutil.TempDir("", "mvc_assets")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(assets)
+
+	createFolder(assets, "css", t)
+	createTemplateFile(assets+"/css", "critical.css", "body{margin:0}", t)
+
+	SetAssetRoot(assets)
+
+	defer func() { assetFS = nil }()
+
+	root := setupTestViews(map[string]string{
+		"base.html": `<style>{{inline "css/critical.css"}}</style><style>{{inline "css/missing.css"}}</style><style>{{inline "../../etc/passwd"}}</style>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("home")
+
+	c.Render("index")
+
+	body := string(c.ResponseWriter.(*mockResponseWriter).Body())
+
+	if !strings.HasPrefix(body, "<style>body{margin:0}</style>") {
+		t.Errorf("Result was '%s', expected the inlined css", body)
+	}
+
+	if !strings.Contains(body, `/* inline: "css/missing.css" is not available */`) {
+		t.Errorf("Result was '%s', expected a comment for the missing file", body)
+	}
+
+	if strings.Contains(body, "root:") || !strings.Contains(body, `/* inline: "etc/passwd" is not available */`) {
+		t.Errorf("Result was '%s', expected paths outside the asset root to be rejected", body)
+	}
+}
+
+func TestAssetManifest(t *testing.T) {
+	SetAssetManifest(map[string]string{"app.css": "/static/app.3f2a9c.css"})
+
+	defer SetAssetManifest(nil)
+
+	root := setupTestViews(map[string]string{
+		"base.html": `<link href="{{asset "app.css"}}"><script src="{{asset "/static/app.js"}}"></script>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("home")
+
+	c.Render("index")
+
+	expected := `<link href="/static/app.3f2a9c.css"><script src="/static/app.js"></script>`
+
+	if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != expected {
+		t.Errorf("Result was '%s', expected '%s'", body, expected)
+	}
+}
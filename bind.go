@@ -0,0 +1,433 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationErrors maps the names of invalid fields to a description of why they are invalid.
+type ValidationErrors map[string]string
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+
+	for field := range e {
+		fields = append(fields, field)
+	}
+
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+
+	for i, field := range fields {
+		messages[i] = field + ": " + e[field]
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// maxCachedBodySize is the largest request body Body will read and cache.
+const maxCachedBodySize = 10 << 20
+
+// Body reads and returns the request body, caching it so that subsequent calls return the same
+// content. The request's Body is replaced by a reader over the cached content, so binders reading
+// it afterwards, e.g. BindJSON, see the full body. Bodies larger than 10MB result in an error.
+func (c *Controller) Body() ([]byte, error) {
+	if c.body != nil {
+		return c.body, nil
+	}
+
+	if c.Request.Body == nil {
+		c.body = []byte{}
+
+		return c.body, nil
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, maxCachedBodySize+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > maxCachedBodySize {
+		return nil, fmt.Errorf("The request body exceeds %d bytes.", maxCachedBodySize)
+	}
+
+	c.Request.Body.Close()
+
+	c.body = b
+
+	c.resetBody()
+
+	return c.body, nil
+}
+
+// resetBody replaces the request's Body with a reader over the cached body.
+func (c *Controller) resetBody() {
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+}
+
+// BindJSON decodes the json request body into dst.
+func (c *Controller) BindJSON(dst interface{}) error {
+	if c.body != nil {
+		c.resetBody()
+	}
+
+	return json.NewDecoder(c.Request.Body).Decode(dst)
+}
+
+// JSONField returns the raw json value of the named member of the json object request body, and
+// whether the member exists, e.g. for an endpoint reading a single field. The body is read via
+// Body, so that it may be read again. If the body is not a json object, false is returned.
+func (c *Controller) JSONField(name string) (json.RawMessage, bool) {
+	body, err := c.Body()
+
+	if err != nil {
+		return nil, false
+	}
+
+	var fields map[string]json.RawMessage
+
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+
+	value, ok := fields[name]
+
+	return value, ok
+}
+
+// maxFormMemory is the number of bytes of a multipart form's files held in memory when parsed,
+// the remainder being stored in temporary files.
+const maxFormMemory = 32 << 20
+
+// ensureFormParsed parses the request's form values once, however many form helpers read them,
+// returning the error of the parse. Multipart forms, as used to upload files, are detected by the
+// request's Content-Type and parsed with their files, otherwise the url encoded form is parsed.
+func (c *Controller) ensureFormParsed() error {
+	if c.formParsed {
+		return c.formErr
+	}
+
+	c.formParsed = true
+
+	if c.body != nil {
+		c.resetBody()
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+
+	if mediaType == "multipart/form-data" {
+		c.formErr = c.Request.ParseMultipartForm(maxFormMemory)
+	} else {
+		c.formErr = c.Request.ParseForm()
+	}
+
+	return c.formErr
+}
+
+// GetFormString returns the posted form value associated with the provided field, or the URL
+// query value if none is posted. If the field does not have a value associated with it, or the
+// form cannot be parsed, the provided default value is returned.
+func (c *Controller) GetFormString(field string, def string) string {
+	if c.ensureFormParsed() != nil {
+		return def
+	}
+
+	values, ok := c.Request.Form[field]
+
+	if !ok || len(values) == 0 {
+		return def
+	}
+
+	return values[0]
+}
+
+// GetUploadedFile returns the first file uploaded via the provided field of a multipart form.
+// The caller is responsible for closing the file. An error is returned if the request is not a
+// multipart form, the form cannot be parsed or no file was uploaded via the field.
+func (c *Controller) GetUploadedFile(field string) (multipart.File, *multipart.FileHeader, error) {
+	if err := c.ensureFormParsed(); err != nil {
+		return nil, nil, err
+	}
+
+	if c.Request.MultipartForm == nil {
+		return nil, nil, http.ErrNotMultipart
+	}
+
+	files := c.Request.MultipartForm.File[field]
+
+	if len(files) == 0 {
+		return nil, nil, http.ErrMissingFile
+	}
+
+	f, err := files[0].Open()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, files[0], nil
+}
+
+// BindForm populates the fields of the struct pointed to by dst from the request's form values,
+// which include the URL query values. A field is populated from the form value named as its
+// "form" tag, or as the field itself if it has no such tag. Fields tagged "-" are skipped.
+// String, bool, numeric fields and slices of these are supported.
+func (c *Controller) BindForm(dst interface{}) error {
+	if err := c.ensureFormParsed(); err != nil {
+		return err
+	}
+
+	return bindValues(dst, "form", c.Request.Form)
+}
+
+// GetFormMap returns the request's posted form values with names of the form "[prefix][key]" as a
+// map, keyed by key, with the first value associated with each, e.g. for "user[name]=matt" the map
+// of "user" is {"name": "matt"}. Names with further nesting, such as "user[address][city]", are
+// skipped. If the form cannot be parsed, an empty map is returned.
+func (c *Controller) GetFormMap(prefix string) map[string]string {
+	m := make(map[string]string)
+
+	if err := c.ensureFormParsed(); err != nil {
+		return m
+	}
+
+	for name, values := range c.Request.PostForm {
+		if !strings.HasPrefix(name, prefix+"[") || !strings.HasSuffix(name, "]") || len(values) == 0 {
+			continue
+		}
+
+		key := name[len(prefix)+1 : len(name)-1]
+
+		if key != "" && !strings.ContainsAny(key, "[]") {
+			m[key] = values[0]
+		}
+	}
+
+	return m
+}
+
+// Bind populates dst from the request, via BindJSON for requests with a json body,
+// otherwise via BindForm.
+func (c *Controller) Bind(dst interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		return c.BindJSON(dst)
+	}
+
+	return c.BindForm(dst)
+}
+
+// BindRequest populates the fields of the struct pointed to by dst from the path parameters of
+// the route the request was dispatched to, for fields with a "param" tag, and from the URL query
+// values, for fields with a "query" tag, e.g.
+//
+//	type orderQuery struct {
+//		ID   int64  `param:"id"`
+//		Sort string `query:"sort"`
+//	}
+//
+// Fields with neither tag are skipped. An error is returned if a path parameter is missing, as
+// the route does not define it, whereas missing query values leave their fields unchanged.
+func (c *Controller) BindRequest(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("The destination must be a pointer to a struct.")
+	}
+
+	v = v.Elem()
+
+	query := c.Request.URL.Query()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if _, ok := field.Tag.Lookup("param"); ok {
+			name := fieldName(field, "param")
+
+			if name == "" {
+				continue
+			}
+
+			value, ok := c.params[name]
+
+			if !ok {
+				return fmt.Errorf("Path parameter %v is missing.", name)
+			}
+
+			if err := setValue(v.Field(i), value); err != nil {
+				return fmt.Errorf("Field %v: %v", name, err)
+			}
+		} else if _, ok := field.Tag.Lookup("query"); ok {
+			name := fieldName(field, "query")
+
+			if s, ok := query[name]; ok && name != "" && len(s) > 0 {
+				if err := setField(v.Field(i), s); err != nil {
+					return fmt.Errorf("Field %v: %v", name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// BindAndValidate populates dst from the request via Bind, then validates it via the provided
+// function, returning the validation errors reported. If binding fails, validation is skipped
+// and the binding error returned.
+func (c *Controller) BindAndValidate(dst interface{}, validate func(dst interface{}) ValidationErrors) (ValidationErrors, error) {
+	if err := c.Bind(dst); err != nil {
+		return nil, err
+	}
+
+	return validate(dst), nil
+}
+
+// bindValues populates the fields of the struct pointed to by dst from values, keyed by the
+// provided tag of each field, or the name of the field if it has no such tag.
+func bindValues(dst interface{}, tag string, values url.Values) error {
+	v := reflect.ValueOf(dst)
+
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("The destination must be a pointer to a struct.")
+	}
+
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		name := fieldName(field, tag)
+
+		if name == "" {
+			continue
+		}
+
+		s, ok := values[name]
+
+		if !ok || len(s) == 0 {
+			continue
+		}
+
+		if err := setField(v.Field(i), s); err != nil {
+			return fmt.Errorf("Field %v: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// fieldName returns the name a struct field is keyed by, as per the provided tag, or the name
+// of the field if it has no such tag. An empty string is returned for fields which are
+// unexported or tagged "-".
+func fieldName(field reflect.StructField, tag string) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+
+	if name == "-" {
+		return ""
+	}
+
+	if name == "" {
+		name = field.Name
+	}
+
+	return name
+}
+
+// setField sets a field from its string representations, parsed according to the field's type.
+func setField(v reflect.Value, s []string) error {
+	if v.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(v.Type(), len(s), len(s))
+
+		for i := range s {
+			if err := setValue(slice.Index(i), s[i]); err != nil {
+				return err
+			}
+		}
+
+		v.Set(slice)
+
+		return nil
+	}
+
+	return setValue(v, s[0])
+}
+
+// setValue sets a value from its string representation, parsed according to the value's type.
+func setValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("Unsupported type %v.", v.Type())
+	}
+
+	return nil
+}
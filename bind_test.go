@@ -0,0 +1,296 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testSignup struct {
+	Name string `form:"name"`
+	Age  int    `form:"age"`
+	Tags []string
+}
+
+func validateSignup(dst interface{}) ValidationErrors {
+	errs := ValidationErrors{}
+
+	if dst.(*testSignup).Age < 18 {
+		errs["age"] = "must be at least 18"
+	}
+
+	return errs
+}
+
+func TestBindAndValidate(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/?Tags=a&Tags=b", strings.NewReader("name=matt&age=12"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c, _ := recordingController("user", r)
+
+	var signup testSignup
+
+	errs, err := c.BindAndValidate(&signup, validateSignup)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signup.Name != "matt" || signup.Age != 12 || strings.Join(signup.Tags, ",") != "a,b" {
+		t.Errorf("Bound %+v, expected {Name:matt Age:12 Tags:[a b]}", signup)
+	}
+
+	if len(errs) != 1 || errs["age"] == "" {
+		t.Errorf("Errors were %v, expected an error for age", errs)
+	}
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(`{"Name": "matt", "Age": "twelve"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	c, _ = recordingController("user", r)
+
+	validated := false
+
+	errs, err = c.BindAndValidate(&testSignup{}, func(dst interface{}) ValidationErrors {
+		validated = true
+		return nil
+	})
+
+	if err == nil || errs != nil || validated {
+		t.Errorf("Expected binding to fail without validating, got error %v and errors %v", err, errs)
+	}
+}
+
+func TestGetFormMap(t *testing.T) {
+	body := "user[name]=matt&user[email]=matt%40example.com&user[address][city]=x&users[name]=y&user=z&user[]=w"
+
+	r, _ := http.NewRequest("POST", "/?user[role]=admin", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c, _ := recordingController("user", r)
+
+	m := c.GetFormMap("user")
+
+	if len(m) != 2 || m["name"] != "matt" || m["email"] != "matt@example.com" {
+		t.Errorf("Result was %v, expected map[email:matt@example.com name:matt]", m)
+	}
+}
+
+func TestBody(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"Name": "matt", "Age": 30}`))
+
+	c, _ := recordingController("user", r)
+
+	body, err := c.Body()
+
+	if err != nil || string(body) != `{"Name": "matt", "Age": 30}` {
+		t.Errorf("Body was '%s' with error %v", body, err)
+	}
+
+	var signup testSignup
+
+	if err := c.BindJSON(&signup); err != nil || signup.Name != "matt" || signup.Age != 30 {
+		t.Errorf("Bound %+v with error %v, expected {Name:matt Age:30}", signup, err)
+	}
+
+	if again, _ := c.Body(); string(again) != string(body) {
+		t.Errorf("Body was '%s' when read again, expected '%s'", again, body)
+	}
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", maxCachedBodySize+1)))
+
+	c, _ = recordingController("user", r)
+
+	if _, err := c.Body(); err == nil {
+		t.Errorf("Expected an error for a body exceeding the limit")
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	type testCase struct {
+		body, name string
+		expected   string
+		ok         bool
+	}
+
+	testCases := []testCase{
+		testCase{`{"status": "shipped", "items": [1, 2]}`, "status", `"shipped"`, true},
+		testCase{`{"status": "shipped", "items": [1, 2]}`, "items", `[1, 2]`, true},
+		testCase{`{"status": null}`, "status", `null`, true},
+		testCase{`{"status": "shipped"}`, "note", "", false},
+		testCase{`["status"]`, "status", "", false},
+		testCase{`{"status":`, "status", "", false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+
+		c, _ := recordingController("order", r)
+
+		if value, ok := c.JSONField(tc.name); string(value) != tc.expected || ok != tc.ok {
+			t.Errorf("%s of %s: result was '%s', %v, expected '%s', %v", tc.name, tc.body, value, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+type testOrderRequest struct {
+	Owner string   `param:"owner"`
+	ID    int64    `param:"id"`
+	Sort  string   `query:"sort"`
+	Tags  []string `query:"tag"`
+	Page  int
+}
+
+func TestBindRequest(t *testing.T) {
+	rt := NewRouter()
+
+	bind := func(c *Controller) error {
+		dst := testOrderRequest{Sort: "date", Page: 1}
+
+		if err := c.BindRequest(&dst); err != nil {
+			return NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		c.TextContent(fmt.Sprintf("%+v", dst))
+
+		return nil
+	}
+
+	rt.HandleErr("GET", "/users/:owner/orders/:id", "order", bind)
+	rt.HandleErr("GET", "/orders/:id", "order", bind)
+
+	type testCase struct {
+		url, expected string
+		status        int
+	}
+
+	testCases := []testCase{
+		testCase{"/users/matt/orders/7?sort=total&tag=a&tag=b&Page=3", "{Owner:matt ID:7 Sort:total Tags:[a b] Page:1}", http.StatusOK},
+		testCase{"/users/matt/orders/7", "{Owner:matt ID:7 Sort:date Tags:[] Page:1}", http.StatusOK},
+		testCase{"/users/matt/orders/x", "Field id: strconv.ParseInt: parsing \"x\": invalid syntax\n", http.StatusBadRequest},
+		testCase{"/orders/7", "Path parameter owner is missing.\n", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		w := serveRouter(rt, "GET", tc.url)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d '%s', expected %d '%s'", tc.url, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+}
+
+// countingReader counts the reads made of the underlying reader once exhausted.
+type countingReader struct {
+	r    io.Reader
+	eofs int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+
+	if err == io.EOF {
+		r.eofs++
+	}
+
+	return n, err
+}
+
+func (r *countingReader) Close() error { return nil }
+
+func TestFormParsedOnce(t *testing.T) {
+	body := &countingReader{r: strings.NewReader("name=matt&age=30&user[role]=admin")}
+
+	r, _ := http.NewRequest("POST", "/?Tags=a", nil)
+	r.Body = body
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c, _ := recordingController("user", r)
+
+	if name := c.GetFormString("name", ""); name != "matt" {
+		t.Errorf("Name was '%s', expected 'matt'", name)
+	}
+
+	var signup testSignup
+
+	if err := c.BindForm(&signup); err != nil {
+		t.Fatal(err)
+	}
+
+	if signup.Name != "matt" || signup.Age != 30 || strings.Join(signup.Tags, ",") != "a" {
+		t.Errorf("Bound %+v, expected {Name:matt Age:30 Tags:[a]}", signup)
+	}
+
+	if m := c.GetFormMap("user"); m["role"] != "admin" {
+		t.Errorf("Map was %v, expected map[role:admin]", m)
+	}
+
+	if body.eofs != 1 {
+		t.Errorf("The body was read to the end %d times, expected once", body.eofs)
+	}
+
+	if _, _, err := c.GetUploadedFile("avatar"); err != http.ErrNotMultipart {
+		t.Errorf("Error was %v, expected %v", err, http.ErrNotMultipart)
+	}
+}
+
+func TestGetUploadedFile(t *testing.T) {
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+
+	mw.WriteField("name", "matt")
+
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+
+	fw.Write([]byte("image"))
+
+	mw.Close()
+
+	r, _ := http.NewRequest("POST", "/", &buf)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	c, _ := recordingController("user", r)
+
+	if name := c.GetFormString("name", ""); name != "matt" {
+		t.Errorf("Name was '%s', expected 'matt'", name)
+	}
+
+	f, header, err := c.GetUploadedFile("avatar")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	content, _ := io.ReadAll(f)
+
+	if header.Filename != "avatar.png" || string(content) != "image" {
+		t.Errorf("File was %s '%s', expected avatar.png 'image'", header.Filename, content)
+	}
+
+	if _, _, err := c.GetUploadedFile("missing"); err != http.ErrMissingFile {
+		t.Errorf("Error was %v, expected %v", err, http.ErrMissingFile)
+	}
+}
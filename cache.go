@@ -0,0 +1,116 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCachedRenders is the maximum number of rendered outputs held by the render cache.
+const maxCachedRenders = 1000
+
+// cachedRender is the output of a rendered view, held by the render cache until it expires.
+type cachedRender struct {
+	body    []byte
+	expires time.Time
+}
+
+var (
+	renderCache      = make(map[string]cachedRender)
+	renderCacheMutex sync.Mutex
+)
+
+// RenderCached has the same functionality as RenderViewModel, except that the output of the
+// view is cached for the provided duration, keyed by the controller, view and model. Renders
+// of the same view and model within that duration are served from the cache, without
+// executing the view's templates. As such, the view must not depend on anything other than
+// its model, e.g. request specific template functions such as "csrf" or the ViewBag.
+// Post-processing of the output, e.g. the after render hook, is still applied per request.
+// Models which cannot be marshalled as json are never cached.
+func (c *Controller) RenderCached(view string, viewModel interface{}, ttl time.Duration) {
+	key, ok := renderCacheKey(c.Name, view, viewModel)
+
+	if ok {
+		renderCacheMutex.Lock()
+		entry, hit := renderCache[key]
+		renderCacheMutex.Unlock()
+
+		if hit && time.Now().Before(entry.expires) {
+			// post-processing may modify the body in place, so it works on a copy of the entry
+			writeRendered(c, append([]byte(nil), entry.body...))
+			return
+		}
+	}
+
+	body, err := renderBytes(c, c.Name, view, c.newView(c.Name, view, viewModel))
+
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	if ok {
+		storeRender(key, cachedRender{append([]byte(nil), body...), time.Now().Add(ttl)})
+	}
+
+	writeRendered(c, body)
+}
+
+// renderCacheKey returns the key the output of a view rendered with the provided model is cached by.
+func renderCacheKey(controllerName, view string, viewModel interface{}) (string, bool) {
+	b, err := json.Marshal(viewModel)
+
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha1.Sum(b)
+
+	return controllerName + "/" + view + "/" + hex.EncodeToString(sum[:]), true
+}
+
+// storeRender adds an entry to the render cache. When the cache is full, expired entries are
+// evicted, and if none have expired, an arbitrary entry.
+func storeRender(key string, entry cachedRender) {
+	renderCacheMutex.Lock()
+	defer renderCacheMutex.Unlock()
+
+	if _, ok := renderCache[key]; !ok && len(renderCache) >= maxCachedRenders {
+		now := time.Now()
+
+		for k, v := range renderCache {
+			if now.After(v.expires) {
+				delete(renderCache, k)
+			}
+		}
+
+		for k := range renderCache {
+			if len(renderCache) < maxCachedRenders {
+				break
+			}
+
+			delete(renderCache, k)
+		}
+	}
+
+	renderCache[key] = entry
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRenderCached(t *testing.T) {
+	executions := 0
+
+	viewConfig = nil
+
+	err := SetupViewsWithConfig(SetupViewsConfig{
+		FS: fstest.MapFS{
+			"views/base.html": {Data: []byte(`{{count}}{{.Model}}`)},
+		},
+		Root: "views",
+		Funcs: template.FuncMap{
+			"count": func() string {
+				executions++
+				return ""
+			},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		c := mockController("page")
+
+		c.RenderCached("about", "hello", time.Minute)
+
+		if string(c.ResponseWriter.(*mockResponseWriter).Body()) != "hello" {
+			t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), "hello")
+		}
+	}
+
+	if executions != 1 {
+		t.Errorf("Expected the view to be executed once, was executed %d times", executions)
+	}
+
+	mockController("page").RenderCached("about", "other", time.Minute)
+
+	if executions != 2 {
+		t.Errorf("Expected a different model to be cached separately, executed %d times", executions)
+	}
+
+	mockController("page").RenderCached("expiring", "hello", 0)
+	mockController("page").RenderCached("expiring", "hello", 0)
+
+	if executions != 4 {
+		t.Errorf("Expected expired output to be rendered again, executed %d times", executions)
+	}
+}
+
+func TestRenderCachedAfterRenderIsolation(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	// modifies the body in place
+	SetAfterRender(func(c *Controller, body []byte) []byte {
+		copy(body, bytes.ToUpper(body))
+		return body
+	})
+
+	for i := 0; i < 2; i++ {
+		c := mockController("page")
+
+		c.RenderCached("isolated", "hello", time.Minute)
+
+		if result := string(c.ResponseWriter.(*mockResponseWriter).Body()); result != "<P>HELLO</P>" {
+			t.Errorf("Result was '%s', expected '%s'", result, "<P>HELLO</P>")
+		}
+	}
+
+	SetAfterRender(nil)
+
+	c := mockController("page")
+
+	c.RenderCached("isolated", "hello", time.Minute)
+
+	if result := string(c.ResponseWriter.(*mockResponseWriter).Body()); result != "<p>hello</p>" {
+		t.Errorf("Result was '%s', expected the cached output to be unaffected by the after render hook", result)
+	}
+}
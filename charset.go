@@ -0,0 +1,96 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// charsets are the character sets, other than utf-8, text responses can be transcoded to.
+var charsets = map[string]encoding.Encoding{
+	"iso-8859-1":   charmap.ISO8859_1,
+	"iso-8859-15":  charmap.ISO8859_15,
+	"windows-1252": charmap.Windows1252,
+}
+
+var charsetNegotiation bool
+
+// SetCharsetNegotiation sets whether the content written by TextContent and HtmlContent is
+// transcoded to the character set most preferred by the request's Accept-Charset header,
+// of utf-8, iso-8859-1, iso-8859-15 and windows-1252, falling back to utf-8.
+// Characters not representable in the negotiated character set are replaced, or escaped as
+// character references in html.
+func SetCharsetNegotiation(enabled bool) {
+	charsetNegotiation = enabled
+}
+
+// negotiateCharset returns the supported character set most preferred by the request's
+// Accept-Charset header, or utf-8 if none of the supported character sets are acceptable.
+func (c *Controller) negotiateCharset() string {
+	for _, v := range parseQualityValues(c.Request.Header.Get("Accept-Charset")) {
+		if v.quality <= 0 {
+			continue
+		}
+
+		if v.value == "utf-8" || v.value == "*" {
+			return "utf-8"
+		}
+
+		if _, ok := charsets[v.value]; ok {
+			return v.value
+		}
+	}
+
+	return "utf-8"
+}
+
+// writeText writes text content of the provided media type to the response, transcoded
+// to the negotiated character set if enabled via SetCharsetNegotiation.
+func (c *Controller) writeText(mediaType, text string) {
+	if !charsetNegotiation {
+		c.ResponseWriter.Header().Set("Content-Type", mediaType)
+		c.writeContent(0, []byte(text))
+		return
+	}
+
+	charset := c.negotiateCharset()
+
+	body := []byte(text)
+
+	if enc, ok := charsets[charset]; ok {
+		encoder := encoding.ReplaceUnsupported(enc.NewEncoder())
+
+		if mediaType == "text/html" {
+			encoder = encoding.HTMLEscapeUnsupported(enc.NewEncoder())
+		}
+
+		transcoded, err := encoder.String(text)
+
+		if err == nil {
+			body = []byte(transcoded)
+		} else {
+			charset = "utf-8"
+		}
+	}
+
+	c.AddVary("Accept-Charset")
+	c.ResponseWriter.Header().Set("Content-Type", mediaType+"; charset="+strings.ToUpper(charset))
+	c.writeContent(0, body)
+}
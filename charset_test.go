@@ -0,0 +1,57 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestCharsetNegotiation(t *testing.T) {
+	SetCharsetNegotiation(true)
+
+	defer SetCharsetNegotiation(false)
+
+	type testCase struct {
+		acceptCharset, contentType string
+		expected                   []byte
+	}
+
+	testCases := []testCase{
+		testCase{"iso-8859-1, utf-8;q=0.5", "text/html; charset=ISO-8859-1", []byte("caf\xe9 &#8364;")},
+		testCase{"utf-8, iso-8859-1;q=0.5", "text/html; charset=UTF-8", []byte("café €")},
+		testCase{"koi8-r", "text/html; charset=UTF-8", []byte("café €")},
+		testCase{"", "text/html; charset=UTF-8", []byte("café €")},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		if tc.acceptCharset != "" {
+			r.Header.Set("Accept-Charset", tc.acceptCharset)
+		}
+
+		c, w := recordingController("home", r)
+
+		c.HtmlContent("café €")
+
+		if !bytes.Equal(w.Body.Bytes(), tc.expected) || w.Header().Get("Content-Type") != tc.contentType {
+			t.Errorf("%s: result was %q '%s', expected %q '%s'", tc.acceptCharset, w.Body.Bytes(), w.Header().Get("Content-Type"), tc.expected, tc.contentType)
+		}
+	}
+}
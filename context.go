@@ -0,0 +1,137 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// contextKey is the type of the keys used by this package to store values in a request's
+// context. As the type is unexported, its keys cannot collide with those of other packages,
+// even if their underlying values are equal. Applications storing their own request scoped
+// values are encouraged to follow the same pattern.
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	breadcrumbsKey
+	requestIDKey
+	featureFlagsKey
+	logFieldsKey
+)
+
+// withValue stores a value in the request's context under the provided key.
+func (c *Controller) withValue(key contextKey, value interface{}) {
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), key, value))
+}
+
+// ClientGone returns whether the request's context is done, as the client disconnected or the
+// request was otherwise cancelled, so that actions can abandon expensive work whose output
+// would never be received.
+func (c *Controller) ClientGone() bool {
+	select {
+	case <-c.Request.Context().Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// SetUser stores the user associated with the request, e.g. as loaded by a filter.
+func (c *Controller) SetUser(u interface{}) {
+	c.withValue(userKey, u)
+}
+
+// User returns the user stored via SetUser, or nil if none has been stored.
+func (c *Controller) User() interface{} {
+	return c.Request.Context().Value(userKey)
+}
+
+// Breadcrumb is an entry of the navigation trail leading to a page.
+type Breadcrumb struct {
+	Label string
+	URL   string
+}
+
+// PushBreadcrumb appends an entry to the request's breadcrumb trail, which is available
+// to views, in the order pushed, via the "breadcrumbs" view template function.
+func (c *Controller) PushBreadcrumb(label, url string) {
+	trail, ok := c.Request.Context().Value(breadcrumbsKey).(*[]Breadcrumb)
+
+	if !ok {
+		trail = &[]Breadcrumb{}
+
+		c.withValue(breadcrumbsKey, trail)
+	}
+
+	*trail = append(*trail, Breadcrumb{label, url})
+}
+
+// Breadcrumbs returns the request's breadcrumb trail, in the order pushed.
+func (c *Controller) Breadcrumbs() []Breadcrumb {
+	if trail, ok := c.Request.Context().Value(breadcrumbsKey).(*[]Breadcrumb); ok {
+		return *trail
+	}
+
+	return nil
+}
+
+// RequestID returns the identifier of the request, for tracing it across logs and services.
+// The identifier is taken from the request's X-Request-ID header if valid, otherwise one is
+// generated. It is stored in the request's context, and echoed in the response's X-Request-ID
+// header. A Router assigns every request an identifier before dispatching it.
+func (c *Controller) RequestID() string {
+	if id, ok := c.Request.Context().Value(requestIDKey).(string); ok {
+		return id
+	}
+
+	id := c.Request.Header.Get("X-Request-ID")
+
+	if !validRequestID(id) {
+		b := make([]byte, 16)
+
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+
+		id = hex.EncodeToString(b)
+	}
+
+	c.withValue(requestIDKey, id)
+
+	c.ResponseWriter.Header().Set("X-Request-ID", id)
+
+	return id
+}
+
+// validRequestID returns whether a client provided request identifier is safe to use,
+// being non empty, of a reasonable length and consisting of printable ascii characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, r := range id {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
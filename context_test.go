@@ -0,0 +1,129 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestUser(t *testing.T) {
+	c := mockController("home")
+
+	if c.User() != nil {
+		t.Errorf("Expected no user, got %v", c.User())
+	}
+
+	// A value stored under an equal key of another type does not collide.
+
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), 0, "other"))
+
+	c.SetUser("matt")
+
+	if c.User() != "matt" {
+		t.Errorf("User was %v, expected %v", c.User(), "matt")
+	}
+
+	if c.Request.Context().Value(0) != "other" {
+		t.Errorf("Expected the other value to be unaffected, got %v", c.Request.Context().Value(0))
+	}
+}
+
+func pushHome(c *Controller) { c.PushBreadcrumb("Home", "/") }
+
+func pushSection(c *Controller) { c.PushBreadcrumb("Posts", "/posts") }
+
+func pushPage(c *Controller) { c.PushBreadcrumb("First", "/posts/1") }
+
+func TestBreadcrumbs(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{range breadcrumbs}}<a href="{{.URL}}">{{.Label}}</a>{{end}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("post")
+
+	pushHome(c)
+	pushSection(c)
+	pushPage(c)
+
+	c.Render("show")
+
+	expected := `<a href="/">Home</a><a href="/posts">Posts</a><a href="/posts/1">First</a>`
+
+	if string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+
+	if len(mockController("post").Breadcrumbs()) != 0 {
+		t.Errorf("Expected breadcrumbs to be scoped to the request")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<body data-request="{{requestID}}"></body>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	rt := NewRouter()
+
+	rt.Handle("GET", "/", "home", func(c *Controller) { c.Render("index") })
+
+	w := serveRouter(rt, "GET", "/")
+
+	id := w.Header().Get("X-Request-ID")
+
+	if len(id) != 32 {
+		t.Errorf("Expected a generated request ID, got '%s'", id)
+	}
+
+	if expected := `<body data-request="` + id + `"></body>`; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-ID", "upstream-42")
+
+	c, w := recordingController("home", r)
+
+	if c.RequestID() != "upstream-42" || w.Header().Get("X-Request-ID") != "upstream-42" {
+		t.Errorf("Expected the incoming request ID to be used, got '%s'", c.RequestID())
+	}
+}
+
+func TestClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, _ := recordingController("home", r.WithContext(ctx))
+
+	if c.ClientGone() {
+		t.Errorf("Expected the client not to be gone before the context is cancelled")
+	}
+
+	cancel()
+
+	if !c.ClientGone() {
+		t.Errorf("Expected the client to be gone once the context is cancelled")
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+)
+
+const (
+	// csrfCookieName is the name of the cookie storing a client's CSRF token.
+	csrfCookieName = "csrf_token"
+	// csrfFieldName is the name of the form field a CSRF token is submitted with.
+	csrfFieldName = "csrf_token"
+	// csrfHeaderName is the name of the header a CSRF token is submitted with, e.g. by ajax requests.
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+var csrfEnabled bool
+
+var csrfMetaTagEnabled bool
+
+// SetCSRFProtection sets whether a Router rejects requests with unsafe methods, e.g. POST,
+// which do not submit the client's CSRF token, with a 403 Forbidden status. The token is
+// stored in a cookie, and must be submitted via a form field named "csrf_token" or the
+// X-CSRF-Token header. The "csrf" view template function provides the token.
+func SetCSRFProtection(enabled bool) {
+	csrfEnabled = enabled
+}
+
+// SetCSRFMetaTag sets whether, while CSRF protection is enabled, a
+// <meta name="csrf-token"> tag is injected into the <head> of rendered html views,
+// so that the token is available to javascript.
+func SetCSRFMetaTag(enabled bool) {
+	csrfMetaTagEnabled = enabled
+}
+
+// CSRFToken returns the client's CSRF token, generating one and storing it in a cookie if the
+// client does not have one yet.
+func (c *Controller) CSRFToken() string {
+	if c.csrfToken != "" {
+		return c.csrfToken
+	}
+
+	if cookie, err := c.Request.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		c.csrfToken = cookie.Value
+
+		return c.csrfToken
+	}
+
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	c.csrfToken = base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(c.ResponseWriter, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    c.csrfToken,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return c.csrfToken
+}
+
+// VerifyCSRF returns whether the request is safe from cross site request forgery, either
+// as its method is safe, or as it submits the CSRF token stored in the client's cookie.
+func (c *Controller) VerifyCSRF() bool {
+	switch c.Request.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+
+	cookie, err := c.Request.Cookie(csrfCookieName)
+
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	token := c.Request.Header.Get(csrfHeaderName)
+
+	if token == "" {
+		if c.ensureFormParsed() == nil {
+			token = c.Request.PostForm.Get(csrfFieldName)
+		}
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// injectCSRFMetaTag injects a <meta name="csrf-token"> tag at the start of the <head> of a
+// rendered html view, when enabled via SetCSRFMetaTag while CSRF protection is enabled.
+func injectCSRFMetaTag(c *Controller, body []byte) []byte {
+	if !csrfEnabled || !csrfMetaTagEnabled || !isHtml(c, body) {
+		return body
+	}
+
+	i := headTagIndex(body)
+
+	if i < 0 {
+		return body
+	}
+
+	end := bytes.IndexByte(body[i:], '>')
+
+	if end < 0 {
+		return body
+	}
+
+	end += i + 1
+
+	tag := `<meta name="csrf-token" content="` + template.HTMLEscapeString(c.CSRFToken()) + `">`
+
+	result := make([]byte, 0, len(body)+len(tag))
+
+	result = append(result, body[:end]...)
+	result = append(result, tag...)
+
+	return append(result, body[end:]...)
+}
+
+// headTagIndex returns the index of the <head> start tag of an html document, or -1 if it has
+// none. Tags merely starting with "head", e.g. <header>, are not matched.
+func headTagIndex(body []byte) int {
+	lower := bytes.ToLower(body)
+
+	for offset := 0; ; {
+		i := bytes.Index(lower[offset:], []byte("<head"))
+
+		if i < 0 {
+			return -1
+		}
+
+		end := offset + i + len("<head")
+
+		if end < len(lower) {
+			switch lower[end] {
+			case '>', ' ', '\t', '\n', '\r', '\f':
+				return offset + i
+			}
+		}
+
+		offset = end
+	}
+}
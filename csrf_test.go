@@ -0,0 +1,138 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCSRFMetaTag(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `<html><head><title>t</title></head><body>{{template "content.html" .}}</body></html>`,
+		"home/index/content.html": `<form><input name="csrf_token" value="{{csrf}}"></form>`,
+		"api/base.html":           `plain text`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetCSRFProtection(true)
+	SetCSRFMetaTag(true)
+
+	defer SetCSRFProtection(false)
+	defer SetCSRFMetaTag(false)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.Render("index")
+
+	tag := `<meta name="csrf-token" content="` + c.CSRFToken() + `">`
+
+	if strings.Count(w.Body.String(), tag) != 1 || !strings.Contains(w.Body.String(), "<head>"+tag+"<title>") {
+		t.Errorf("Result was '%s', expected it to contain '%s' once", w.Body.String(), tag)
+	}
+
+	if !strings.Contains(w.Header().Get("Set-Cookie"), c.CSRFToken()) {
+		t.Errorf("Expected the token to be stored in a cookie, Set-Cookie was '%s'", w.Header().Get("Set-Cookie"))
+	}
+
+	c, w = recordingController("api", r)
+
+	c.Render("index")
+
+	if w.Body.String() != "plain text" {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), "plain text")
+	}
+}
+
+func TestCSRFMetaTagHeadMatch(t *testing.T) {
+	SetCSRFProtection(true)
+	SetCSRFMetaTag(true)
+
+	defer SetCSRFProtection(false)
+	defer SetCSRFMetaTag(false)
+
+	type testCase struct {
+		body, expected string
+	}
+
+	testCases := []testCase{
+		testCase{`<html><header class="top">x</header></html>`, `<html><header class="top">x</header></html>`},
+		testCase{`<html><header>x</header><HEAD lang="en"></HEAD></html>`, `<html><header>x</header><HEAD lang="en"><meta name="csrf-token" content="token"></HEAD></html>`},
+		testCase{"<html><head\n><title>t</title></head></html>", "<html><head\n><meta name=\"csrf-token\" content=\"token\"><title>t</title></head></html>"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "token"})
+
+		c, w := recordingController("home", r)
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		if result := string(injectCSRFMetaTag(c, []byte(tc.body))); result != tc.expected {
+			t.Errorf("Result was '%s', expected '%s'", result, tc.expected)
+		}
+	}
+}
+
+func TestCSRFProtection(t *testing.T) {
+	SetCSRFProtection(true)
+
+	defer SetCSRFProtection(false)
+
+	rt := NewRouter()
+
+	rt.Handle("POST", "/posts", "post", func(c *Controller) { c.TextContent("created") })
+
+	type testCase struct {
+		cookie, token string
+		status        int
+	}
+
+	testCases := []testCase{
+		testCase{"secret", "secret", http.StatusOK},
+		testCase{"secret", "guess", http.StatusForbidden},
+		testCase{"", "", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		form := url.Values{"csrf_token": {tc.token}}
+
+		r, _ := http.NewRequest("POST", "/posts", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		if tc.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "csrf_token", Value: tc.cookie})
+		}
+
+		w := httptest.NewRecorder()
+
+		rt.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%v: status was %d, expected %d", tc, w.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// CsvContent can be used to write to the response, the provided header and data rows, as csv.
+// The response is served as an attachment named after the controller, e.g. "report.csv".
+func (c *Controller) CsvContent(headers []string, rows [][]string) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	if len(headers) > 0 {
+		w.Write(headers)
+	}
+
+	w.WriteAll(rows)
+
+	c.ResponseWriter.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	c.ResponseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", c.Name+".csv"))
+
+	c.writeContent(0, buf.Bytes())
+}
+
+// CsvContentFromStructs writes the provided slice of structs, or pointers to structs, to the
+// response as csv, via CsvContent. A column is written for each exported field, headed by
+// the field's "csv" tag, or by the name of the field if it has no such tag. Fields tagged
+// "-" are skipped.
+func (c *Controller) CsvContentFromStructs(records interface{}) error {
+	v := reflect.ValueOf(records)
+
+	if v.Kind() != reflect.Slice {
+		return errors.New("The records must be a slice of structs.")
+	}
+
+	t := v.Type().Elem()
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return errors.New("The records must be a slice of structs.")
+	}
+
+	var headers []string
+	var fields []int
+
+	for i := 0; i < t.NumField(); i++ {
+		if name := fieldName(t.Field(i), "csv"); name != "" {
+			headers = append(headers, name)
+			fields = append(fields, i)
+		}
+	}
+
+	rows := make([][]string, v.Len())
+
+	for i := range rows {
+		record := reflect.Indirect(v.Index(i))
+
+		rows[i] = make([]string, len(fields))
+
+		if !record.IsValid() {
+			continue
+		}
+
+		for j, field := range fields {
+			rows[i][j] = fmt.Sprint(record.Field(field).Interface())
+		}
+	}
+
+	c.CsvContent(headers, rows)
+
+	return nil
+}
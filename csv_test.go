@@ -0,0 +1,72 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCsvContent(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/report", nil)
+
+	c, w := recordingController("report", r)
+
+	c.CsvContent([]string{"name", "quote"}, [][]string{
+		{"Smith, John", `He said "hi"`},
+		{"plain", "text"},
+	})
+
+	expected := "name,quote\n\"Smith, John\",\"He said \"\"hi\"\"\"\nplain,text\n"
+
+	if w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	if w.Header().Get("Content-Type") != "text/csv; charset=utf-8" || w.Header().Get("Content-Disposition") != `attachment; filename="report.csv"` {
+		t.Errorf("Headers were %v", w.Header())
+	}
+}
+
+func TestCsvContentFromStructs(t *testing.T) {
+	type record struct {
+		ID       int `csv:"id"`
+		Name     string
+		Password string `csv:"-"`
+		internal string
+	}
+
+	r, _ := http.NewRequest("GET", "/users", nil)
+
+	c, w := recordingController("users", r)
+
+	err := c.CsvContentFromStructs([]record{{1, "matt", "secret", ""}, {2, "Doe, Jane", "secret", ""}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id,Name\n1,matt\n2,\"Doe, Jane\"\n"
+
+	if w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	if err := c.CsvContentFromStructs([]string{"a"}); err == nil {
+		t.Errorf("Expected an error for a slice of non structs")
+	}
+}
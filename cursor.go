@@ -0,0 +1,80 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// EncodeCursor encodes a pagination cursor, e.g. a struct holding the sort key of the last item
+// of a page, as url safe base64 encoded json, to be decoded via DecodeCursor when the next page is
+// requested. An empty string is returned if v cannot be encoded as json.
+func EncodeCursor(v interface{}) string {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes the URL query value associated with the provided query parameter, as
+// encoded by EncodeCursor, into dst. If the query parameter does not have a value associated with
+// it, e.g. for the first page, dst is left unchanged and nil returned. An error is returned for a
+// malformed cursor, e.g. one tampered with by a client. As cursors are not signed, their values
+// must be validated as any other input would be.
+func (c *Controller) DecodeCursor(queryParam string, dst interface{}) error {
+	s := c.GetString(queryParam, "")
+
+	if s == "" {
+		return nil
+	}
+
+	// padding is tolerated, as some clients add it
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+
+	if err != nil {
+		return errors.New("The cursor is malformed.")
+	}
+
+	if err := json.Unmarshal(b, dst); err != nil {
+		return errors.New("The cursor is malformed.")
+	}
+
+	return nil
+}
+
+// SetLinkHeader sets a Link header, as per RFC 8288, referencing the first, previous, next and
+// last pages of a paginated collection, with the rel values "first", "prev", "next" and "last".
+// Empty URLs are omitted, and if all are empty, no header is set.
+func (c *Controller) SetLinkHeader(first, prev, next, last string) {
+	links := make([]string, 0, 4)
+
+	for _, link := range []struct{ url, rel string }{{first, "first"}, {prev, "prev"}, {next, "next"}, {last, "last"}} {
+		if link.url != "" {
+			links = append(links, "<"+link.url+`>; rel="`+link.rel+`"`)
+		}
+	}
+
+	if len(links) > 0 {
+		c.ResponseWriter.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testCursor struct {
+	Created time.Time `json:"created"`
+	ID      int64     `json:"id"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := testCursor{time.Date(2013, 5, 1, 12, 0, 0, 0, time.UTC), 42}
+
+	encoded := EncodeCursor(cursor)
+
+	r, _ := http.NewRequest("GET", "/?after="+url.QueryEscape(encoded), nil)
+
+	c, _ := recordingController("post", r)
+
+	var decoded testCursor
+
+	if err := c.DecodeCursor("after", &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !decoded.Created.Equal(cursor.Created) || decoded.ID != cursor.ID {
+		t.Errorf("Decoded %+v, expected %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	type testCase struct {
+		cursor string
+		err    bool
+	}
+
+	testCases := []testCase{
+		testCase{EncodeCursor(testCursor{ID: 1})[:10] + "!", true},
+		testCase{"bm90IGpzb24", true}, // "not json"
+		testCase{EncodeCursor("a string"), true},
+		testCase{EncodeCursor(testCursor{ID: 1}) + "==", false},
+		testCase{"", false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/?after="+url.QueryEscape(tc.cursor), nil)
+
+		c, _ := recordingController("post", r)
+
+		var decoded testCursor
+
+		if err := c.DecodeCursor("after", &decoded); (err != nil) != tc.err {
+			t.Errorf("%s: error was %v, expected an error: %v", tc.cursor, err, tc.err)
+		}
+	}
+}
+
+func TestSetLinkHeader(t *testing.T) {
+	type testCase struct {
+		first, prev, next, last string
+		expected                string
+	}
+
+	testCases := []testCase{
+		testCase{"", "", "/orders?page=2", "/orders?page=5", `</orders?page=2>; rel="next", </orders?page=5>; rel="last"`},
+		testCase{"/orders?page=1", "/orders?page=4", "", "", `</orders?page=1>; rel="first", </orders?page=4>; rel="prev"`},
+		testCase{"", "", "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/orders", nil)
+
+		c, w := recordingController("orders", r)
+
+		c.SetLinkHeader(tc.first, tc.prev, tc.next, tc.last)
+
+		if result := w.Header().Get("Link"); result != tc.expected {
+			t.Errorf("Result was '%s', expected '%s'", result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// injectDebugToolbar appends a toolbar to the end of the <body> of a rendered html view, showing
+// the directory of the templates rendered, the time taken to render them and the keys of the
+// ViewBag, when views are setup in dev mode. It never appears otherwise.
+func injectDebugToolbar(c *Controller, body []byte) []byte {
+	if viewConfig == nil || !viewConfig.DevMode || !isHtml(c, body) {
+		return body
+	}
+
+	keys := make([]string, 0, len(c.ViewBag))
+
+	for k := range c.ViewBag {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	viewPath := c.viewPath
+
+	if viewPath == "" {
+		viewPath = "(cached)"
+	}
+
+	toolbar := fmt.Sprintf(`<div id="mvc-debug-toolbar" style="position:fixed;bottom:0;left:0;right:0;padding:4px 8px;background:#222;color:#eee;font:12px monospace;z-index:2147483647">`+
+		`view: %s | render: %s | bag: %s</div>`,
+		html.EscapeString(viewPath), c.renderDuration, html.EscapeString(strings.Join(keys, ", ")))
+
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+
+	if i < 0 {
+		return append(body, toolbar...)
+	}
+
+	injected := make([]byte, 0, len(body)+len(toolbar))
+
+	injected = append(injected, body[:i]...)
+	injected = append(injected, toolbar...)
+
+	return append(injected, body[i:]...)
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebugToolbar(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":          `<html><body><p>page</p></body></html>`,
+		"api/data/base.html": `{"a":1}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	render := func(controller string) string {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		c, w := recordingController(controller, r)
+
+		c.ViewBag["title"] = "Home"
+		c.ViewBag["user"] = "matt"
+
+		c.Render("data")
+
+		return w.Body.String()
+	}
+
+	if body := render("home"); strings.Contains(body, "mvc-debug-toolbar") {
+		t.Errorf("Expected no toolbar outside dev mode, got '%s'", body)
+	}
+
+	viewConfig.DevMode = true
+
+	defer func() { viewConfig.DevMode = false }()
+
+	body := render("home")
+
+	if !strings.Contains(body, `<div id="mvc-debug-toolbar"`) || !strings.HasSuffix(body, "</div></body></html>") {
+		t.Errorf("Expected a toolbar at the end of the body in dev mode, got '%s'", body)
+	}
+
+	if !strings.Contains(body, "view: . |") || !strings.Contains(body, "bag: title, user</div>") {
+		t.Errorf("Expected the toolbar to show the view path and bag keys, got '%s'", body)
+	}
+
+	if body := render("api"); body != `{"a":1}` {
+		t.Errorf("Expected no toolbar for a non html response in dev mode, got '%s'", body)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RenderEmail renders a view as RenderViewModel does, returning the output rather than writing it
+// to the response, with the rules of its <style> blocks moved into the style attributes of the
+// elements they select, as many email clients ignore <style> blocks. Only rules with tag, class
+// or id selectors are inlined, e.g. "p", ".note" or "#footer"; any other rules, such as media
+// queries, are left in the <style> blocks. Inlined rules apply in order of specificity, then
+// order of declaration, before any style attribute already present on an element.
+func (c *Controller) RenderEmail(view string, viewModel interface{}) (string, error) {
+	body, err := renderBytes(c, c.Name, view, c.newView(c.Name, view, viewModel))
+
+	if err != nil {
+		return "", err
+	}
+
+	return inlineCSS(string(body)), nil
+}
+
+var (
+	styleBlockPattern = regexp.MustCompile(`(?is)(<style[^>]*>)(.*?)(</style>)`)
+	cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	startTagPattern   = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)(\s[^>]*?)?(/?)>`)
+	attributePattern  = regexp.MustCompile(`(?i)\s([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	simpleSelector    = regexp.MustCompile(`^[.#]?[a-zA-Z][a-zA-Z0-9_-]*$`)
+)
+
+// cssRule is a rule with a simple selector, which can be inlined.
+type cssRule struct {
+	selector     string
+	declarations string
+}
+
+// specificity ranks the rule's selector, an id selector being more specific than a class
+// selector, which is more specific than a tag selector.
+func (r cssRule) specificity() int {
+	switch r.selector[0] {
+	case '#':
+		return 2
+	case '.':
+		return 1
+	}
+
+	return 0
+}
+
+// matches returns whether the rule's selector selects an element with the provided tag and attributes.
+func (r cssRule) matches(tag string, attrs map[string]string) bool {
+	switch r.selector[0] {
+	case '#':
+		return attrs["id"] == r.selector[1:]
+	case '.':
+		for _, class := range strings.Fields(attrs["class"]) {
+			if class == r.selector[1:] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return strings.EqualFold(tag, r.selector)
+}
+
+// inlineCSS moves the rules with simple selectors, of the <style> blocks of an html document,
+// into the style attributes of the elements they select.
+func inlineCSS(doc string) string {
+	var rules []cssRule
+
+	doc = styleBlockPattern.ReplaceAllStringFunc(doc, func(block string) string {
+		m := styleBlockPattern.FindStringSubmatch(block)
+
+		inlinable, remaining := parseCSSRules(m[2])
+
+		rules = append(rules, inlinable...)
+
+		if strings.TrimSpace(remaining) == "" {
+			return ""
+		}
+
+		return m[1] + remaining + m[3]
+	})
+
+	if len(rules) == 0 {
+		return doc
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].specificity() < rules[j].specificity()
+	})
+
+	// the <style> blocks remaining are skipped, so that their contents are left untouched
+	blocks := styleBlockPattern.FindAllStringIndex(doc, -1)
+
+	var b strings.Builder
+
+	last := 0
+
+	for _, loc := range startTagPattern.FindAllStringSubmatchIndex(doc, -1) {
+		if insideAny(loc[0], blocks) {
+			continue
+		}
+
+		tag := doc[loc[2]:loc[3]]
+
+		attrs := ""
+
+		if loc[4] >= 0 {
+			attrs = doc[loc[4]:loc[5]]
+		}
+
+		values := make(map[string]string)
+
+		for _, a := range attributePattern.FindAllStringSubmatch(attrs, -1) {
+			values[strings.ToLower(a[1])] = html.UnescapeString(strings.Trim(a[2], `"'`))
+		}
+
+		var declarations []string
+
+		for _, rule := range rules {
+			if rule.matches(tag, values) {
+				declarations = append(declarations, rule.declarations)
+			}
+		}
+
+		if len(declarations) == 0 {
+			continue
+		}
+
+		if style := strings.TrimSpace(values["style"]); style != "" {
+			declarations = append(declarations, strings.TrimSuffix(style, ";"))
+		}
+
+		attrs = attributePattern.ReplaceAllStringFunc(attrs, func(a string) string {
+			if strings.EqualFold(attributePattern.FindStringSubmatch(a)[1], "style") {
+				return ""
+			}
+
+			return a
+		})
+
+		b.WriteString(doc[last:loc[0]])
+		b.WriteString("<" + tag + attrs + ` style="` + html.EscapeString(strings.Join(declarations, "; ")) + `"` + doc[loc[6]:loc[1]])
+
+		last = loc[1]
+	}
+
+	b.WriteString(doc[last:])
+
+	return b.String()
+}
+
+// insideAny returns whether the offset falls within any of the provided [start, end) ranges.
+func insideAny(offset int, ranges [][]int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCSSRules splits a style sheet into the rules which can be inlined, one per selector,
+// and the remaining style sheet, holding the rules which cannot.
+func parseCSSRules(css string) ([]cssRule, string) {
+	css = cssCommentPattern.ReplaceAllString(css, "")
+
+	var rules []cssRule
+
+	var remaining strings.Builder
+
+	for {
+		open := strings.Index(css, "{")
+
+		if open < 0 {
+			break
+		}
+
+		// find the matching closing brace, so that nested blocks, e.g. of media queries, are kept whole
+		depth, end := 0, -1
+
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+
+		if end < 0 {
+			break
+		}
+
+		selectors := strings.TrimSpace(css[:open])
+		declarations := strings.TrimSuffix(strings.TrimSpace(css[open+1:end]), ";")
+
+		var unsupported []string
+
+		for _, selector := range strings.Split(selectors, ",") {
+			selector = strings.TrimSpace(selector)
+
+			if simpleSelector.MatchString(selector) && !strings.Contains(declarations, "{") {
+				rules = append(rules, cssRule{selector, strings.TrimSpace(declarations)})
+			} else {
+				unsupported = append(unsupported, selector)
+			}
+		}
+
+		if len(unsupported) > 0 {
+			remaining.WriteString(strings.Join(unsupported, ", ") + " {" + css[open+1:end] + "}\n")
+		}
+
+		css = css[end+1:]
+	}
+
+	return rules, remaining.String()
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRenderEmail(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":                  `<html><head><style>.note { color: red; } p { margin: 0 } a:hover { color: blue }</style></head><body>{{template "content.html" .}}</body></html>`,
+		"email/welcome/content.html": `<p class="note intro" style="font-weight: bold">Hi {{.Model}}</p><p>Bye</p><br/>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("email")
+
+	result, err := c.RenderEmail("welcome", "matt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<html><head><style>a:hover { color: blue }
+</style></head><body><p class="note intro" style="margin: 0; color: red; font-weight: bold">Hi matt</p><p style="margin: 0">Bye</p><br/></body></html>`
+
+	if result != expected {
+		t.Errorf("Result was '%s', expected '%s'", result, expected)
+	}
+
+	if len(c.ResponseWriter.(*mockResponseWriter).Body()) != 0 {
+		t.Errorf("Expected nothing to be written to the response")
+	}
+}
+
+func TestInlineCSS(t *testing.T) {
+	type testCase struct {
+		doc, expected string
+	}
+
+	testCases := []testCase{
+		testCase{
+			`<style>#footer { color: grey } .small { font-size: 10px }</style><div id="footer" class="small">x</div>`,
+			`<div id="footer" class="small" style="font-size: 10px; color: grey">x</div>`,
+		},
+		testCase{
+			`<style>@media (max-width: 600px) { p { margin: 0 } }</style><p>x</p>`,
+			`<style>@media (max-width: 600px) { p { margin: 0 } }
+</style><p>x</p>`,
+		},
+		testCase{
+			`<style>h1, td.cell { font-family: "Arial" }</style><h1>x</h1><td class="cell">y</td>`,
+			`<style>td.cell { font-family: "Arial" }
+</style><h1 style="font-family: &#34;Arial&#34;">x</h1><td class="cell">y</td>`,
+		},
+		testCase{`<p>no styles</p>`, `<p>no styles</p>`},
+	}
+
+	for _, tc := range testCases {
+		if result := inlineCSS(tc.doc); result != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.doc, result, tc.expected)
+		}
+	}
+}
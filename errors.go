@@ -0,0 +1,193 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPError is an error mapping to a particular response status and message, e.g. to be
+// returned by an action when a requested resource does not exist.
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+// NewHTTPError can be used to instantiate an HTTPError, with the standard text of the status as its
+// message if none is provided.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	return &HTTPError{status, message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// HandleError writes an error to the response. If err is, or wraps, an *HTTPError its status
+// and message are written, and if an *http.MaxBytesError a 413 Request Entity Too Large status.
+// Otherwise the error is logged and a 500 Internal Server Error status written, without
+// exposing the error's details.
+func (c *Controller) HandleError(err error) {
+	status, err := c.errorStatus(err)
+
+	renderError(c, status, err)
+}
+
+// NegotiateError has the same functionality as HandleError, except that should the request's
+// Accept header prefer json, as per AcceptsJSON, the error is written as a problem details object
+// via ProblemJSON, rather than as rendered by the error view. It can be set as the error handler
+// of a Router, via SetErrorHandler((*Controller).NegotiateError), to serve both browsers and API
+// clients. As the response depends on the Accept header, the Vary header is set accordingly.
+func (c *Controller) NegotiateError(err error) {
+	c.AddVary("Accept")
+
+	status, err := c.errorStatus(err)
+
+	if c.AcceptsJSON() {
+		c.ProblemJSON(status, http.StatusText(status), err.Error(), c.Request.URL.Path, nil)
+		return
+	}
+
+	renderError(c, status, err)
+}
+
+// errorStatus returns the status an error maps to, and the error to expose to the client, as
+// described by HandleError. Errors mapping to a 500 Internal Server Error status are logged.
+func (c *Controller) errorStatus(err error) (int, error) {
+	var httpErr *HTTPError
+
+	if errors.As(err, &httpErr) {
+		return httpErr.Status, httpErr
+	}
+
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge, errors.New(http.StatusText(http.StatusRequestEntityTooLarge))
+	}
+
+	log.Printf("mvc: error in controller %v: %v", c.Name, err)
+
+	return http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError))
+}
+
+var errorViewController, errorView string
+
+// SetErrorView sets a view rendered in place of the plain text responses written for errors,
+// e.g. when a view cannot be rendered or by HandleError. The status and error are available
+// to the view via the "status" and "error" entries of the view's Bag.
+func SetErrorView(controller, view string) {
+	errorViewController, errorView = controller, view
+}
+
+// renderError writes an error to the response with the provided status, by rendering the
+// error view if one is set, otherwise as plain text. If the error view itself cannot be
+// rendered, the error is written as plain text.
+func renderError(c *Controller, status int, err error) {
+	if errorView != "" {
+		c.ViewBag["status"] = status
+		c.ViewBag["error"] = err
+
+		t, viewErr := viewTemplate(c, errorViewController, errorView)
+
+		var buf bytes.Buffer
+
+		if viewErr == nil {
+			viewErr = executeTemplate(&buf, t, entryTemplateName(definedIn(t)), c.newView(errorViewController, errorView, nil))
+		}
+
+		if viewErr == nil {
+			c.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+			c.ResponseWriter.WriteHeader(status)
+			c.ResponseWriter.Write(buf.Bytes())
+			return
+		}
+
+		log.Printf("mvc: error rendering error view %v of controller %v: %v", errorView, errorViewController, viewErr)
+	}
+
+	http.Error(c.ResponseWriter, err.Error(), status)
+}
+
+// ProblemJSON writes a problem details object, as defined by RFC 7807, to the response with the
+// provided status and the Content-Type application/problem+json, e.g.
+//
+//	{"type":"about:blank","title":"Not Found","status":404,"detail":"No such order.","instance":"/orders/1"}
+//
+// The detail and instance members are omitted if empty. Members of extensions are added to the
+// object, e.g. a "type" URI identifying the problem, in place of "about:blank", or an "errors"
+// member listing invalid fields. Extensions cannot replace the title, status, detail or instance.
+func (c *Controller) ProblemJSON(status int, title, detail, instance string, extensions map[string]interface{}) {
+	problem := map[string]interface{}{"type": "about:blank"}
+
+	for k, v := range extensions {
+		problem[k] = v
+	}
+
+	problem["title"] = title
+	problem["status"] = status
+
+	delete(problem, "detail")
+	delete(problem, "instance")
+
+	if detail != "" {
+		problem["detail"] = detail
+	}
+
+	if instance != "" {
+		problem["instance"] = instance
+	}
+
+	c.ResponseWriter.Header().Set("Content-Type", "application/problem+json")
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	enc.SetEscapeHTML(jsonEscapeHTML)
+	enc.Encode(problem)
+
+	c.writeContent(status, buf.Bytes())
+}
+
+// TooManyRequests responds to a rate limited request with a 429 Too Many Requests status, a
+// Retry-After header of the number of seconds after which the request may be retried, rounded
+// up, and a json body of the same, e.g. {"error":"rate_limited","retry_after":30}.
+func (c *Controller) TooManyRequests(retryAfter time.Duration) {
+	seconds := int64(0)
+
+	if retryAfter > 0 {
+		seconds = int64((retryAfter + time.Second - 1) / time.Second)
+	}
+
+	c.ResponseWriter.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+
+	c.writeJson(http.StatusTooManyRequests, struct {
+		Error      string `json:"error"`
+		RetryAfter int64  `json:"retry_after"`
+	}{"rate_limited", seconds})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleError(t *testing.T) {
+	type testCase struct {
+		err      error
+		status   int
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{NewHTTPError(http.StatusNotFound, "No such post."), http.StatusNotFound, "No such post.\n"},
+		testCase{fmt.Errorf("loading post: %w", NewHTTPError(http.StatusNotFound, "")), http.StatusNotFound, "Not Found\n"},
+		testCase{errors.New("connection refused"), http.StatusInternalServerError, "Internal Server Error\n"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		c, w := recordingController("post", r)
+
+		c.HandleError(tc.err)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%v: result was %d '%s', expected %d '%s'", tc.err, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+}
+
+func TestErrorView(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `{{template "content.html" .}}`,
+		"home/index/content.html": `{{.Missing.Field}}`,
+		"error/show/content.html": `<h1>{{.Bag.status}}</h1>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.Render("index")
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Missing") {
+		t.Errorf("Result was %d '%s', expected a plain text error", w.Code, w.Body.String())
+	}
+
+	SetErrorView("error", "show")
+
+	defer SetErrorView("", "")
+
+	c, w = recordingController("home", r)
+
+	c.Render("index")
+
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "<h1>500</h1>" {
+		t.Errorf("Result was %d '%s', expected %d '%s'", w.Code, w.Body.String(), http.StatusInternalServerError, "<h1>500</h1>")
+	}
+
+	c, w = recordingController("home", r)
+
+	c.HandleError(NewHTTPError(http.StatusNotFound, ""))
+
+	if w.Code != http.StatusNotFound || w.Body.String() != "<h1>404</h1>" {
+		t.Errorf("Result was %d '%s', expected %d '%s'", w.Code, w.Body.String(), http.StatusNotFound, "<h1>404</h1>")
+	}
+}
+
+func TestNegotiateError(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `{{template "content.html" .}}`,
+		"error/show/content.html": `<h1>{{.Bag.status}} {{.Bag.error}}</h1>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetErrorView("error", "show")
+
+	defer SetErrorView("", "")
+
+	rt := NewRouter()
+
+	rt.HandleErr("GET", "/orders/:id", "order", func(c *Controller) error {
+		return NewHTTPError(http.StatusNotFound, "No such order.")
+	})
+
+	rt.SetErrorHandler((*Controller).NegotiateError)
+
+	type testCase struct {
+		accept      string
+		contentType string
+		expected    string
+	}
+
+	testCases := []testCase{
+		testCase{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<h1>404 No such order.</h1>"},
+		testCase{"application/json", "application/problem+json", `{"detail":"No such order.","instance":"/orders/7","status":404,"title":"Not Found","type":"about:blank"}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+
+		r, _ := http.NewRequest("GET", "/orders/7", nil)
+
+		r.Header.Set("Accept", tc.accept)
+
+		rt.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != tc.contentType || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d %s '%s', expected %d %s '%s'", tc.accept, w.Code, w.Header().Get("Content-Type"), w.Body.String(), http.StatusNotFound, tc.contentType, tc.expected)
+		}
+
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s: Vary was '%s', expected 'Accept'", tc.accept, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestProblemJSON(t *testing.T) {
+	type testCase struct {
+		name                    string
+		status                  int
+		title, detail, instance string
+		extensions              map[string]interface{}
+		expected                string
+	}
+
+	testCases := []testCase{
+		testCase{"required members", http.StatusNotFound, "Not Found", "No such order.", "/orders/1", nil,
+			`{"detail":"No such order.","instance":"/orders/1","status":404,"title":"Not Found","type":"about:blank"}`},
+		testCase{"empty members omitted", http.StatusForbidden, "Forbidden", "", "", nil,
+			`{"status":403,"title":"Forbidden","type":"about:blank"}`},
+		testCase{"extensions", http.StatusBadRequest, "Invalid order", "", "", map[string]interface{}{
+			"type": "https://example.com/probs/invalid", "errors": ValidationErrors{"quantity": "is required"}, "status": 200, "detail": "x",
+		}, `{"errors":{"quantity":"is required"},"status":400,"title":"Invalid order","type":"https://example.com/probs/invalid"}`},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/orders/1", nil)
+
+		c, w := recordingController("order", r)
+
+		c.ProblemJSON(tc.status, tc.title, tc.detail, tc.instance, tc.extensions)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status was %d, expected %d", tc.name, w.Code, tc.status)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("%s: Content-Type was '%s', expected 'application/problem+json'", tc.name, contentType)
+		}
+
+		if w.Body.String() != tc.expected+"\n" {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.name, w.Body.String(), tc.expected)
+		}
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	type testCase struct {
+		retryAfter   time.Duration
+		header, body string
+	}
+
+	testCases := []testCase{
+		testCase{30 * time.Second, "30", `{"error":"rate_limited","retry_after":30}`},
+		testCase{1500 * time.Millisecond, "2", `{"error":"rate_limited","retry_after":2}`},
+		testCase{-time.Second, "0", `{"error":"rate_limited","retry_after":0}`},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("POST", "/orders", nil)
+
+		c, w := recordingController("order", r)
+
+		c.TooManyRequests(tc.retryAfter)
+
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%v: status was %d, expected %d", tc.retryAfter, w.Code, http.StatusTooManyRequests)
+		}
+
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != tc.header {
+			t.Errorf("%v: Retry-After was '%s', expected '%s'", tc.retryAfter, retryAfter, tc.header)
+		}
+
+		if w.Body.String() != tc.body+"\n" {
+			t.Errorf("%v: result was '%s', expected '%s'", tc.retryAfter, w.Body.String(), tc.body)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+var etagsEnabled bool
+
+// SetETags sets whether rendered views are served with an ETag header, computed from the
+// rendered output. Requests with a matching If-None-Match header receive a 304 Not Modified.
+func SetETags(enabled bool) {
+	etagsEnabled = enabled
+}
+
+var weakETags bool
+
+// SetWeakETags sets whether the ETags set via SetETags are weak validators, e.g. W/"...", which
+// indicate that responses with the same tag are semantically equivalent, rather than identical
+// byte for byte, e.g. when they may be transformed by a proxy.
+func SetWeakETags(weak bool) {
+	weakETags = weak
+}
+
+// computeETag returns a quoted entity tag for the provided content. The encoding the
+// content is served with is part of the tag, as differently encoded representations
+// of the same content must not share an entity tag.
+func computeETag(content []byte, gzipped bool) string {
+	sum := sha1.Sum(content)
+
+	tag := hex.EncodeToString(sum[:])
+
+	if gzipped {
+		tag += "-gzip"
+	}
+
+	if weakETags {
+		return `W/"` + tag + `"`
+	}
+
+	return `"` + tag + `"`
+}
+
+// etagMatches returns whether the request's If-None-Match header matches the provided entity tag.
+// As per RFC 7232, If-None-Match uses the weak comparison, where two tags match if their opaque
+// tags are equal, whether either or both are weak, e.g. W/"1" matches "1".
+func etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+
+	if header == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JsonContentCached writes the provided model to the response as json, as JsonContent does,
+// tagged with an ETag computed from the json, weak if set via SetWeakETags. Requests with a
+// matching If-None-Match header receive a 304 Not Modified status without the json.
+func (c *Controller) JsonContentCached(model interface{}) {
+	b, err := encodeJSON(model)
+
+	if err != nil {
+		c.JsonContent(model)
+		return
+	}
+
+	body := append(b, '\n')
+
+	etag := computeETag(body, false)
+
+	c.ResponseWriter.Header().Set("ETag", etag)
+
+	if etagMatches(c.Request, etag) {
+		c.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	c.ResponseWriter.Header().Set("Content-Type", "application/javascript")
+
+	c.writeContent(0, body)
+}
+
+var strictIfMatch bool
+
+// SetStrictIfMatch sets whether CheckIfMatch requires requests to have an If-Match header.
+func SetStrictIfMatch(strict bool) {
+	strictIfMatch = strict
+}
+
+// CheckIfMatch supports optimistic concurrency control, returning whether the request's If-Match
+// header matches the current ETag of the resource it would modify. If it does not, a 412
+// Precondition Failed status is written and false returned. Requests without an If-Match header
+// are allowed, unless strict checking is set via SetStrictIfMatch, in which case a 428
+// Precondition Required status is written and false returned.
+func (c *Controller) CheckIfMatch(currentETag string) bool {
+	header := c.Request.Header.Get("If-Match")
+
+	if header == "" {
+		if strictIfMatch {
+			http.Error(c.ResponseWriter, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+			return false
+		}
+
+		return true
+	}
+
+	if !strings.HasPrefix(currentETag, `"`) && !strings.HasPrefix(currentETag, `W/"`) {
+		currentETag = `"` + currentETag + `"`
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		// If-Match uses the strong comparison, so weak tags never match.
+		if tag == "*" || (tag == currentETag && !strings.HasPrefix(tag, "W/")) {
+			return true
+		}
+	}
+
+	http.Error(c.ResponseWriter, http.StatusText(http.StatusPreconditionFailed), http.StatusPreconditionFailed)
+
+	return false
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestETagVariesByEncoding(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>hello</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetGzip(true)
+	SetETags(true)
+
+	defer SetGzip(false)
+	defer SetETags(false)
+
+	plain, _ := http.NewRequest("GET", "/", nil)
+
+	c, pw := recordingController("home", plain)
+
+	c.Render("index")
+
+	compressed, _ := http.NewRequest("GET", "/", nil)
+	compressed.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	c, gw := recordingController("home", compressed)
+
+	c.Render("index")
+
+	if pw.Header().Get("ETag") == "" || pw.Header().Get("ETag") == gw.Header().Get("ETag") {
+		t.Errorf("Expected distinct ETags, got '%s' and '%s'", pw.Header().Get("ETag"), gw.Header().Get("ETag"))
+	}
+
+	for _, w := range []http.Header{pw.Header(), gw.Header()} {
+		if w.Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Vary was '%s', expected '%s'", w.Get("Vary"), "Accept-Encoding")
+		}
+	}
+
+	if pw.Header().Get("Content-Encoding") != "" || pw.Body.String() != "<p>hello</p>" {
+		t.Errorf("Expected an uncompressed response, got '%s'", pw.Body.String())
+	}
+
+	gz, err := gzip.NewReader(gw.Body)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(gz)
+
+	if gw.Header().Get("Content-Encoding") != "gzip" || string(body) != "<p>hello</p>" {
+		t.Errorf("Expected a gzip response, got '%s'", body)
+	}
+
+	// A conditional request only matches the ETag of its own encoding.
+
+	plain.Header.Set("If-None-Match", gw.Header().Get("ETag"))
+
+	c, w := recordingController("home", plain)
+
+	c.Render("index")
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusOK)
+	}
+
+	compressed.Header.Set("If-None-Match", gw.Header().Get("ETag"))
+
+	c, w = recordingController("home", compressed)
+
+	c.Render("index")
+
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	type testCase struct {
+		ifMatch string
+		strict  bool
+		ok      bool
+		status  int
+	}
+
+	testCases := []testCase{
+		testCase{`"v2"`, false, true, http.StatusOK},
+		testCase{`"v1", "v2"`, false, true, http.StatusOK},
+		testCase{`"v1"`, false, false, http.StatusPreconditionFailed},
+		testCase{`W/"v2"`, false, false, http.StatusPreconditionFailed},
+		testCase{"", false, true, http.StatusOK},
+		testCase{"", true, false, http.StatusPreconditionRequired},
+	}
+
+	for _, tc := range testCases {
+		SetStrictIfMatch(tc.strict)
+
+		r, _ := http.NewRequest("PUT", "/posts/1", nil)
+
+		if tc.ifMatch != "" {
+			r.Header.Set("If-Match", tc.ifMatch)
+		}
+
+		c, w := recordingController("post", r)
+
+		if ok := c.CheckIfMatch("v2"); ok != tc.ok || w.Code != tc.status {
+			t.Errorf("%v: result was %v with status %d, expected %v with status %d", tc, ok, w.Code, tc.ok, tc.status)
+		}
+	}
+
+	SetStrictIfMatch(false)
+}
+
+func TestETagWeakComparison(t *testing.T) {
+	type testCase struct {
+		ifNoneMatch, etag string
+		expected          bool
+	}
+
+	testCases := []testCase{
+		testCase{`W/"1"`, `"1"`, true},
+		testCase{`W/"1"`, `W/"1"`, true},
+		testCase{`"1"`, `W/"1"`, true},
+		testCase{`"1"`, `"1"`, true},
+		testCase{`W/"1"`, `"2"`, false},
+		testCase{`W/"1"`, `W/"2"`, false},
+		testCase{`"2", W/"1"`, `"1"`, true},
+		testCase{`1`, `"1"`, false},
+		testCase{`*`, `W/"1"`, true},
+		testCase{``, `"1"`, false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("If-None-Match", tc.ifNoneMatch)
+
+		if result := etagMatches(r, tc.etag); result != tc.expected {
+			t.Errorf("%s against %s: result was %v, expected %v", tc.ifNoneMatch, tc.etag, result, tc.expected)
+		}
+	}
+}
+
+func TestWeakETags(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>hello</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetETags(true)
+	SetWeakETags(true)
+
+	defer SetETags(false)
+	defer SetWeakETags(false)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.Render("index")
+
+	etag := w.Header().Get("ETag")
+
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("ETag was '%s', expected a weak ETag", etag)
+	}
+
+	// a client may send the strong form of the tag, which matches under the weak comparison
+	r.Header.Set("If-None-Match", strings.TrimPrefix(etag, "W/"))
+
+	c, w = recordingController("home", r)
+
+	c.Render("index")
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestJsonContentCached(t *testing.T) {
+	model := map[string]int{"id": 1}
+
+	r, _ := http.NewRequest("GET", "/orders/1", nil)
+
+	c, w := recordingController("order", r)
+
+	c.JsonContentCached(model)
+
+	etag := w.Header().Get("ETag")
+
+	if w.Code != http.StatusOK || w.Body.String() != "{\"id\":1}\n" || etag == "" {
+		t.Errorf("Result was %d '%s' with ETag '%s', expected 200 '{\"id\":1}' with an ETag", w.Code, w.Body.String(), etag)
+	}
+
+	r.Header.Set("If-None-Match", etag)
+
+	c, w = recordingController("order", r)
+
+	c.JsonContentCached(model)
+
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Errorf("Result was %d '%s', expected 304 without a body", w.Code, w.Body.String())
+	}
+
+	c, w = recordingController("order", r)
+
+	c.JsonContentCached(map[string]int{"id": 2})
+
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Status was %d with ETag '%s', expected 200 with a new ETag for a changed model", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import "sync"
+
+var (
+	featureFlags      map[string]bool
+	featureFlagsMutex sync.RWMutex
+)
+
+// SetFeatureFlags sets which features are enabled, keyed by name, e.g. for a gradual rollout.
+// Features absent from flags are disabled. Flags can be overridden per request via OverrideFeature.
+func SetFeatureFlags(flags map[string]bool) {
+	copied := make(map[string]bool, len(flags))
+
+	for name, enabled := range flags {
+		copied[name] = enabled
+	}
+
+	featureFlagsMutex.Lock()
+	featureFlags = copied
+	featureFlagsMutex.Unlock()
+}
+
+// OverrideFeature enables or disables the named feature for the request only, e.g. for a filter
+// assigning users to a group of an A/B test. The override is stored in the request's context.
+func (c *Controller) OverrideFeature(name string, enabled bool) {
+	overrides, ok := c.Request.Context().Value(featureFlagsKey).(map[string]bool)
+
+	if !ok {
+		overrides = make(map[string]bool)
+
+		c.withValue(featureFlagsKey, overrides)
+	}
+
+	overrides[name] = enabled
+}
+
+// FeatureEnabled returns whether the named feature is enabled for the request, as overridden via
+// OverrideFeature, otherwise as set via SetFeatureFlags. The same value is returned by the
+// "feature" view template function.
+func (c *Controller) FeatureEnabled(name string) bool {
+	if overrides, ok := c.Request.Context().Value(featureFlagsKey).(map[string]bool); ok {
+		if enabled, ok := overrides[name]; ok {
+			return enabled
+		}
+	}
+
+	featureFlagsMutex.RLock()
+	defer featureFlagsMutex.RUnlock()
+
+	return featureFlags[name]
+}
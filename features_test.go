@@ -0,0 +1,63 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{if feature "new-ui"}}new{{else}}old{{end}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	defer SetFeatureFlags(nil)
+
+	type testCase struct {
+		name     string
+		flags    map[string]bool
+		override []bool
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{"unset", nil, nil, "old"},
+		testCase{"on", map[string]bool{"new-ui": true}, nil, "new"},
+		testCase{"off", map[string]bool{"new-ui": false, "other": true}, nil, "old"},
+		testCase{"overridden on", map[string]bool{"new-ui": false}, []bool{true}, "new"},
+		testCase{"overridden off", map[string]bool{"new-ui": true}, []bool{false}, "old"},
+	}
+
+	for _, tc := range testCases {
+		SetFeatureFlags(tc.flags)
+
+		c := mockController("home")
+
+		for _, enabled := range tc.override {
+			c.OverrideFeature("new-ui", enabled)
+		}
+
+		c.Render("index")
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.name, body, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the unit prefixes of formatted byte sizes, of increasing magnitude.
+var byteUnits = []string{"K", "M", "G", "T", "P", "E"}
+
+// formatBytes formats a byte count for display, e.g. "1.5 MB", in multiples of base, of
+// either 1024 for binary units or 1000 for SI units. Sizes are rounded to one decimal place.
+func formatBytes(n int64, base int64) string {
+	sign := ""
+
+	if n < 0 {
+		sign, n = "-", -n
+	}
+
+	if n < base {
+		return sign + strconv.FormatInt(n, 10) + " B"
+	}
+
+	size, i := float64(n)/float64(base), 0
+
+	for size >= float64(base)-0.05 && i < len(byteUnits)-1 {
+		size /= float64(base)
+		i++
+	}
+
+	unit := byteUnits[i] + "B"
+
+	if base == 1000 && i == 0 {
+		unit = "kB"
+	}
+
+	s := strings.TrimSuffix(strconv.FormatFloat(size, 'f', 1, 64), ".0")
+
+	return sign + s + " " + unit
+}
+
+// plural returns the singular form for a count of 1, otherwise the plural form, zero included.
+// A form containing "%d" is formatted with the count, e.g. "%d items" as "3 items".
+func plural(count int, singular, pluralForm string) string {
+	form := pluralForm
+
+	if count == 1 {
+		form = singular
+	}
+
+	if strings.Contains(form, "%d") {
+		return fmt.Sprintf(form, count)
+	}
+
+	return form
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	type testCase struct {
+		n          int64
+		binary, si string
+	}
+
+	testCases := []testCase{
+		testCase{0, "0 B", "0 B"},
+		testCase{1023, "1023 B", "1 kB"},
+		testCase{1024, "1 KB", "1 kB"},
+		testCase{1536, "1.5 KB", "1.5 kB"},
+		testCase{1048575, "1 MB", "1 MB"},
+		testCase{5 << 30, "5 GB", "5.4 GB"},
+		testCase{2500000000, "2.3 GB", "2.5 GB"},
+		testCase{-2048, "-2 KB", "-2 kB"},
+	}
+
+	for _, tc := range testCases {
+		if result := formatBytes(tc.n, 1024); result != tc.binary {
+			t.Errorf("%d: binary result was '%s', expected '%s'", tc.n, result, tc.binary)
+		}
+
+		if result := formatBytes(tc.n, 1000); result != tc.si {
+			t.Errorf("%d: SI result was '%s', expected '%s'", tc.n, result, tc.si)
+		}
+	}
+}
+
+func TestBytesTemplateFunctions(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{bytes .Model}} {{bytesSI .Model}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("files")
+
+	c.RenderViewModel("index", int64(3<<30))
+
+	if expected := "3 GB 3.2 GB"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{plural .Model "item" "items"}}, {{plural .Model "%d file" "%d files"}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		count    int
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{0, "items, 0 files"},
+		testCase{1, "item, 1 file"},
+		testCase{2, "items, 2 files"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("files")
+
+		c.RenderViewModel("index", tc.count)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%d: result was '%s', expected '%s'", tc.count, body, tc.expected)
+		}
+	}
+}
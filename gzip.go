@@ -0,0 +1,64 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+var gzipEnabled bool
+
+// SetGzip sets whether rendered views are gzip compressed for requests which accept it.
+func SetGzip(enabled bool) {
+	gzipEnabled = enabled
+}
+
+// acceptsGzip returns whether the request's Accept-Encoding header allows a gzip response.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+
+		name := strings.TrimSpace(parts[0])
+
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		if len(parts) > 1 && strings.Replace(strings.TrimSpace(parts[1]), " ", "", -1) == "q=0" {
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// gzipBytes returns the gzip compressed form of b.
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+
+	gz.Write(b)
+	gz.Close()
+
+	return buf.Bytes()
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// IdempotencyKey returns the request's Idempotency-Key header, a unique value chosen by a client
+// so that a request which may have failed can be safely retried, or an empty string if none.
+func (c *Controller) IdempotencyKey() string {
+	return c.Request.Header.Get("Idempotency-Key")
+}
+
+// StoredResponse is a response stored by an IdempotencyStore, to be replayed for duplicate requests.
+type StoredResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// RequestHash is the hex encoded SHA-256 hash of the body of the request the response was
+	// written for, so that a request reusing its key with a different body can be rejected.
+	RequestHash string
+}
+
+// IdempotencyStore stores the responses to requests with an idempotency key, as set via
+// Router.SetIdempotencyStore, e.g. in memory or a shared cache.
+type IdempotencyStore interface {
+	// Get returns the response stored for the key, and whether one is stored.
+	Get(key string) (*StoredResponse, bool)
+	// Put stores the response for the key.
+	Put(key string, response *StoredResponse)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore holding the responses in memory, without
+// expiry, suitable for a single process serving a bounded number of keys, e.g. in tests.
+type MemoryIdempotencyStore struct {
+	responses map[string]*StoredResponse
+	mutex     sync.RWMutex
+}
+
+// NewMemoryIdempotencyStore can be used to instantiate a MemoryIdempotencyStore instance.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{responses: make(map[string]*StoredResponse)}
+}
+
+// Get returns the response stored for the key, and whether one is stored.
+func (s *MemoryIdempotencyStore) Get(key string) (*StoredResponse, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	response, ok := s.responses[key]
+
+	return response, ok
+}
+
+// Put stores the response for the key.
+func (s *MemoryIdempotencyStore) Put(key string, response *StoredResponse) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.responses[key] = response
+}
+
+// SetIdempotencyStore sets the store of the responses to POST and PATCH requests with an
+// Idempotency-Key header. A request with the same key, method and path as a request already
+// served is not dispatched to its action, the stored response being replayed in its place.
+// Responses with a 5xx status are not stored, so that such requests can be retried. Requests
+// are checked after filters are run, so that a replayed response is only served to requests
+// allowed by the filters. Duplicate requests served concurrently may both be dispatched.
+// Keys are scoped to the caller, as set via SetIdempotencyScope, and to whether the response may
+// be gzipped. A request reusing a key with a different body is answered with a 422 Unprocessable
+// Entity status via the error handler. Set-Cookie headers are not stored.
+func (rt *Router) SetIdempotencyStore(store IdempotencyStore) {
+	rt.idempotencyStore = store
+}
+
+// SetIdempotencyScope sets the function returning the caller a request's idempotency key is
+// scoped to, e.g. the ID of the signed in user, so that a caller cannot be replayed the response
+// to another's request by reusing its key. By default keys are scoped to the client's IP address,
+// as per ClientIP.
+func (rt *Router) SetIdempotencyScope(fn func(c *Controller) string) {
+	rt.idempotencyScope = fn
+}
+
+// idempotencyStoreKey returns the key a response is stored by, or an empty string if the
+// request's response is not stored.
+func (rt *Router) idempotencyStoreKey(c *Controller) string {
+	key := c.IdempotencyKey()
+
+	if key == "" || (c.Request.Method != "POST" && c.Request.Method != "PATCH") {
+		return ""
+	}
+
+	scope := rt.idempotencyScope
+
+	if scope == nil {
+		scope = (*Controller).ClientIP
+	}
+
+	// the stored body may be gzipped, so gzip and identity responses are stored separately
+	encoding := "identity"
+
+	if acceptsGzip(c.Request) {
+		encoding = "gzip"
+	}
+
+	return fmt.Sprintf("%s %s %s %q %q", c.Request.Method, c.Request.URL.Path, encoding, scope(c), key)
+}
+
+// replayResponse writes a stored response. Headers already set for the request being served,
+// e.g. its X-Request-ID, are not replaced.
+func replayResponse(w http.ResponseWriter, response *StoredResponse) {
+	for k, v := range response.Header {
+		if _, ok := w.Header()[k]; !ok {
+			w.Header()[k] = append([]string(nil), v...)
+		}
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// dispatchIdempotent runs an action, replaying the stored response instead if the request is a
+// duplicate of one already served, otherwise storing the response written by the action.
+func (rt *Router) dispatchIdempotent(c *Controller, action ErrorAction) {
+	key := rt.idempotencyStoreKey(c)
+
+	if key == "" {
+		rt.dispatch(c, action)
+		return
+	}
+
+	body, err := c.Body()
+
+	if err != nil {
+		rt.errorHandler(c, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+
+	requestHash := hex.EncodeToString(sum[:])
+
+	if response, ok := rt.idempotencyStore.Get(key); ok {
+		if response.RequestHash != requestHash {
+			rt.errorHandler(c, NewHTTPError(http.StatusUnprocessableEntity, "The Idempotency-Key was used for a different request."))
+			return
+		}
+
+		replayResponse(c.ResponseWriter, response)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: c.ResponseWriter, capture: &bytes.Buffer{}}
+
+	c.ResponseWriter = rec
+
+	rt.dispatch(c, action)
+
+	if rec.Status() < 500 {
+		header := rec.Header().Clone()
+
+		// cookies, e.g. the CSRF token's, belong to the client the response was written for
+		header.Del("Set-Cookie")
+
+		rt.idempotencyStore.Put(key, &StoredResponse{rec.Status(), header, rec.capture.Bytes(), requestHash})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	rt := NewRouter()
+
+	rt.SetIdempotencyStore(NewMemoryIdempotencyStore())
+
+	runs := 0
+
+	rt.Handle("POST", "/orders", "order", func(c *Controller) {
+		runs++
+
+		c.ResponseWriter.Header().Set("Location", fmt.Sprintf("/orders/%d", runs))
+		c.writeJson(http.StatusCreated, map[string]int{"id": runs})
+	})
+
+	post := func(key, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+
+		r, _ := http.NewRequest("POST", path, strings.NewReader("{}"))
+
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+
+		rt.ServeHTTP(w, r)
+
+		return w
+	}
+
+	first, second := post("abc", "/orders"), post("abc", "/orders")
+
+	if runs != 1 {
+		t.Errorf("The action ran %d times, expected once", runs)
+	}
+
+	if second.Code != first.Code || second.Body.String() != first.Body.String() || second.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("Replayed %d '%s', expected %d '%s'", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+
+	if first.Code != http.StatusCreated || first.Body.String() != "{\"id\":1}\n" {
+		t.Errorf("Result was %d '%s', expected 201 '{\"id\":1}'", first.Code, first.Body.String())
+	}
+
+	if second.Header().Get("X-Request-ID") == first.Header().Get("X-Request-ID") {
+		t.Errorf("Expected the replayed response to keep its own request ID")
+	}
+
+	post("def", "/orders")
+	post("", "/orders")
+
+	if runs != 3 {
+		t.Errorf("The action ran %d times, expected 3 for distinct and absent keys", runs)
+	}
+}
+
+func TestIdempotencyStoreIsolation(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>order {{.Model}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetGzip(true)
+
+	defer SetGzip(false)
+
+	rt := NewRouter()
+
+	rt.SetIdempotencyStore(NewMemoryIdempotencyStore())
+
+	runs := 0
+
+	rt.Handle("POST", "/orders", "order", func(c *Controller) {
+		runs++
+
+		http.SetCookie(c.ResponseWriter, &http.Cookie{Name: "session", Value: strconv.Itoa(runs)})
+
+		c.RenderViewModel("index", runs)
+	})
+
+	post := func(remoteAddr, body, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+
+		r, _ := http.NewRequest("POST", "/orders", strings.NewReader(body))
+
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Idempotency-Key", "abc")
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+
+		rt.ServeHTTP(w, r)
+
+		return w
+	}
+
+	first := post("203.0.113.1:5000", `{"item":1}`, "gzip")
+
+	if first.Header().Get("Content-Encoding") != "gzip" || first.Header().Get("Set-Cookie") == "" {
+		t.Fatalf("Expected a gzipped response setting a cookie, headers were %v", first.Header())
+	}
+
+	// the same client, without gzip
+	plain := post("203.0.113.1:5000", `{"item":1}`, "")
+
+	if runs != 2 || plain.Header().Get("Content-Encoding") != "" || plain.Body.String() != "<p>order 2</p>" {
+		t.Errorf("Result was '%s' with Content-Encoding '%s' after %d runs, expected a plain response", plain.Body.String(), plain.Header().Get("Content-Encoding"), runs)
+	}
+
+	// the same client, replayed
+	replayed := post("203.0.113.1:5000", `{"item":1}`, "")
+
+	if runs != 2 || replayed.Header().Get("Idempotent-Replayed") != "true" || replayed.Body.String() != "<p>order 2</p>" {
+		t.Errorf("Result was '%s' after %d runs, expected the replayed response", replayed.Body.String(), runs)
+	}
+
+	if cookie := replayed.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("Set-Cookie was '%s', expected cookies not to be replayed", cookie)
+	}
+
+	// another client reusing the key
+	other := post("198.51.100.9:5000", `{"item":1}`, "")
+
+	if runs != 3 || other.Header().Get("Idempotent-Replayed") != "" || other.Body.String() != "<p>order 3</p>" {
+		t.Errorf("Result was '%s' after %d runs, expected another client's request to be dispatched", other.Body.String(), runs)
+	}
+
+	// the same client reusing the key with a different body
+	changed := post("203.0.113.1:5000", `{"item":2}`, "")
+
+	if runs != 3 || changed.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Result was %d '%s' after %d runs, expected %d", changed.Code, changed.Body.String(), runs, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	rt := NewRouter()
+
+	rt.SetIdempotencyStore(NewMemoryIdempotencyStore())
+
+	rt.SetIdempotencyScope(func(c *Controller) string {
+		return c.Request.Header.Get("X-User")
+	})
+
+	runs := 0
+
+	rt.Handle("POST", "/orders", "order", func(c *Controller) {
+		runs++
+		c.TextContent(strconv.Itoa(runs))
+	})
+
+	for _, user := range []string{"matt", "matt", "anna"} {
+		r, _ := http.NewRequest("POST", "/orders", strings.NewReader("{}"))
+
+		r.Header.Set("Idempotency-Key", "abc")
+		r.Header.Set("X-User", user)
+
+		rt.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	if runs != 2 {
+		t.Errorf("The action ran %d times, expected once per user", runs)
+	}
+}
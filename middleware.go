@@ -0,0 +1,135 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Middleware is the signature of a function wrapping the handling of every request served by a
+// Router, e.g. to recover from panics or add headers, before it is dispatched.
+type Middleware func(http.Handler) http.Handler
+
+// namedMiddleware is a middleware registered with a Router, with its name if registered via UseNamed.
+type namedMiddleware struct {
+	name string
+	mw   Middleware
+}
+
+// Use registers middleware, run in the order registered, the first registered being the
+// outermost, around the dispatch of every request. Each Middleware is called to wrap the
+// dispatch once, when middleware is registered or removed, rather than for every request.
+func (rt *Router) Use(mw ...Middleware) {
+	for _, m := range mw {
+		rt.middleware = append(rt.middleware, namedMiddleware{"", m})
+	}
+
+	rt.buildHandler()
+}
+
+// UseNamed registers middleware as Use does, under the provided name, so that its position can
+// be referenced via InsertBefore and it can be removed via Remove. An error is returned if
+// middleware is already registered under the name.
+func (rt *Router) UseNamed(name string, mw ...Middleware) error {
+	if name == "" {
+		return errors.New("A middleware name is required.")
+	}
+
+	if rt.middlewareIndex(name) >= 0 {
+		return fmt.Errorf("Middleware %q is already registered.", name)
+	}
+
+	for _, m := range mw {
+		rt.middleware = append(rt.middleware, namedMiddleware{name, m})
+	}
+
+	rt.buildHandler()
+
+	return nil
+}
+
+// InsertBefore registers middleware to run immediately before the middleware registered under
+// the provided name. An error is returned if no middleware is registered under the name.
+func (rt *Router) InsertBefore(name string, mw ...Middleware) error {
+	i := rt.middlewareIndex(name)
+
+	if i < 0 {
+		return fmt.Errorf("Middleware %q is not registered.", name)
+	}
+
+	inserted := make([]namedMiddleware, 0, len(rt.middleware)+len(mw))
+
+	inserted = append(inserted, rt.middleware[:i]...)
+
+	for _, m := range mw {
+		inserted = append(inserted, namedMiddleware{"", m})
+	}
+
+	rt.middleware = append(inserted, rt.middleware[i:]...)
+
+	rt.buildHandler()
+
+	return nil
+}
+
+// Remove removes the middleware registered under the provided name. An error is returned if no
+// middleware is registered under the name.
+func (rt *Router) Remove(name string) error {
+	if rt.middlewareIndex(name) < 0 {
+		return fmt.Errorf("Middleware %q is not registered.", name)
+	}
+
+	remaining := rt.middleware[:0]
+
+	for _, m := range rt.middleware {
+		if m.name != name {
+			remaining = append(remaining, m)
+		}
+	}
+
+	rt.middleware = remaining
+
+	rt.buildHandler()
+
+	return nil
+}
+
+// middlewareIndex returns the index of the first middleware registered under the provided name,
+// or -1 if there is none.
+func (rt *Router) middlewareIndex(name string) int {
+	for i, m := range rt.middleware {
+		if name != "" && m.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// buildHandler builds the handler of the Router's requests, wrapped by its middleware, which is
+// rebuilt whenever middleware is registered or removed.
+func (rt *Router) buildHandler() {
+	var h http.Handler = http.HandlerFunc(rt.serve)
+
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		h = rt.middleware[i].mw(h)
+	}
+
+	rt.handler = h
+}
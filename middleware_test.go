@@ -0,0 +1,130 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordOrder returns middleware appending its name to the trace of the request's order of execution.
+func recordOrder(name string, trace *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var trace []string
+
+	rt := NewRouter()
+
+	rt.Handle("GET", "/", "home", func(c *Controller) {
+		trace = append(trace, "action")
+	})
+
+	rt.Use(recordOrder("recover", &trace))
+
+	if err := rt.UseNamed("auth", recordOrder("auth", &trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.UseNamed("gzip", recordOrder("gzip", &trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rt.InsertBefore("auth", recordOrder("session", &trace), recordOrder("csrf", &trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	serveRouter(rt, "GET", "/")
+
+	if expected := "recover,session,csrf,auth,gzip,action"; strings.Join(trace, ",") != expected {
+		t.Errorf("Order was %v, expected %s", trace, expected)
+	}
+
+	if err := rt.UseNamed("auth", recordOrder("auth", &trace)); err == nil {
+		t.Errorf("Expected an error registering a duplicate name")
+	}
+
+	if err := rt.InsertBefore("missing", recordOrder("x", &trace)); err == nil {
+		t.Errorf("Expected an error inserting before an unregistered name")
+	}
+
+	if err := rt.Remove("gzip"); err != nil {
+		t.Fatal(err)
+	}
+
+	trace = nil
+
+	serveRouter(rt, "GET", "/")
+
+	if expected := "recover,session,csrf,auth,action"; strings.Join(trace, ",") != expected {
+		t.Errorf("Order after removal was %v, expected %s", trace, expected)
+	}
+}
+
+func TestMiddlewareBuiltOnce(t *testing.T) {
+	rt := NewRouter()
+
+	rt.Handle("GET", "/", "home", func(c *Controller) { c.TextContent("home") })
+
+	wraps, served := 0, 0
+
+	rt.Use(func(next http.Handler) http.Handler {
+		wraps++
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served++
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	for i := 0; i < 3; i++ {
+		serveRouter(rt, "GET", "/")
+	}
+
+	if wraps != 1 || served != 3 {
+		t.Errorf("Middleware wrapped %d times and served %d requests, expected 1 and 3", wraps, served)
+	}
+
+	var trace []string
+
+	if err := rt.UseNamed("trace", recordOrder("trace", &trace)); err != nil {
+		t.Fatal(err)
+	}
+
+	serveRouter(rt, "GET", "/")
+
+	if wraps != 2 || len(trace) != 1 {
+		t.Errorf("Middleware wrapped %d times with trace %v, expected the chain to be rebuilt once", wraps, trace)
+	}
+
+	if err := rt.Remove("trace"); err != nil {
+		t.Fatal(err)
+	}
+
+	serveRouter(rt, "GET", "/")
+
+	if wraps != 3 || len(trace) != 1 {
+		t.Errorf("Middleware wrapped %d times with trace %v, expected the removed middleware not to run", wraps, trace)
+	}
+}
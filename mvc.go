@@ -18,15 +18,23 @@ limitations under the License.
 package mvc
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"io/fs"
+	"log"
 	"net/http"
+	"net/netip"
 	"os"
 	"path"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Controller provides a base type, from which a user defined controller would extend.
@@ -34,7 +42,23 @@ type Controller struct {
 	http.ResponseWriter
 	Request *http.Request
 	Name    string
+	// Action is the name of the action the request was dispatched to by a Router, if known.
+	Action  string
 	ViewBag map[string]interface{}
+
+	view      *View
+	nonce     string
+	csrfToken string
+	aborted   bool
+	params    map[string]string
+	body      []byte
+
+	formParsed bool
+	formErr    error
+
+	// viewPath and renderDuration describe the last view rendered, as shown by the debug toolbar.
+	viewPath       string
+	renderDuration time.Duration
 }
 
 // View is a type pre-populated by this framework, with values accessible within views.
@@ -43,6 +67,10 @@ type View struct {
 	Name       string
 	Bag        map[string]interface{}
 	Model      interface{}
+	// Action is the name of the action rendering the view, or the name of the view if unknown.
+	Action string
+	// Title is the "title" entry of Bag, if it is a string, available to templates as {{.Title}}.
+	Title string
 }
 
 // IsView is a helper method, callable on the View instance passed into a view template.
@@ -65,24 +93,141 @@ func (v *View) IsViewForController(viewName, controller string) bool {
 
 var templates map[string]*template.Template
 
+// viewFiles holds the paths of the template files each entry of the templates map was parsed from.
+var viewFiles map[string][]string
+
+// templatesMutex guards the templates and viewFiles maps, which is replaced whenever the views are reparsed.
+var templatesMutex sync.RWMutex
+
+// viewConfig holds the configuration the views were setup with.
+var viewConfig *SetupViewsConfig
+
+// viewRootDir is the root directory of the views, within the file system they are read from.
 var viewRootDir string = ""
 
+// SetupViewsConfig defines the configuration used to setup views.
+type SetupViewsConfig struct {
+	// FS is the file system views are read from, e.g. an embed.FS.
+	// If nil, views are read from the operating system's file system.
+	FS fs.FS
+	// Root is the root view directory within FS.
+	Root string
+	// Extension is the file extension of view templates, ".html" if not set.
+	Extension string
+	// Funcs defines additional functions callable within view templates.
+	Funcs template.FuncMap
+	// DevMode causes views to be reparsed before every render, so that changes
+	// to templates are picked up without a restart.
+	DevMode bool
+}
+
 // SetupViews pre-populates the templates map with parsed view templates.
 func SetupViews(rootDir string) error {
-	if viewRootDir != "" {
+	return SetupViewsWithConfig(SetupViewsConfig{Root: rootDir})
+}
+
+// SetupViewsWithConfig pre-populates the templates map with view templates parsed
+// according to the provided configuration.
+func SetupViewsWithConfig(cfg SetupViewsConfig) error {
+	if viewConfig != nil {
 		return errors.New("Views cannot have more than one root directory.")
 	}
 
-	templates = make(map[string]*template.Template)
+	if cfg.Root == "" {
+		return errors.New("Views must have a root directory.")
+	}
+
+	if cfg.FS == nil {
+		cfg.FS = os.DirFS(cfg.Root)
+		cfg.Root = "."
+	}
+
+	if cfg.Extension == "" {
+		cfg.Extension = ".html"
+	}
+
+	viewConfig = &cfg
+
+	viewRootDir = cfg.Root
 
-	viewRootDir = rootDir
+	if err := parseViews(); err != nil {
+		// allows the views to be setup again once the error is fixed
+		viewConfig = nil
+
+		return err
+	}
 
-	return parseViewDirectory(viewRootDir, nil)
+	return nil
+}
+
+// parseViews (re)populates the templates map by parsing the configured view root directory.
+func parseViews() error {
+	parsed := make(map[string]*template.Template)
+	files := make(map[string][]string)
+
+	shared, err := sharedViewTemplates()
+
+	if err != nil {
+		return err
+	}
+
+	err = parseViewDirectory(parsed, files, viewRootDir, shared, nil)
+
+	if err != nil {
+		return err
+	}
+
+	templatesMutex.Lock()
+	templates = parsed
+	viewFiles = files
+	resolvedTemplates = make(map[string]resolvedTemplate)
+	templatesMutex.Unlock()
+
+	return nil
+}
+
+var baseTemplate string
+
+// SetBaseTemplateName sets the name of the template executed to render a view, e.g.
+// "layout.html", in place of the default "base" template, e.g. "base.html".
+func SetBaseTemplateName(name string) {
+	baseTemplate = name
+}
+
+// baseTemplateName returns the name of the template executed to render a view.
+func baseTemplateName() string {
+	if baseTemplate != "" {
+		return baseTemplate
+	}
+
+	return "base" + viewConfig.Extension
+}
+
+// entryTemplateName returns the name of the template executed to render a view, the base template
+// if defined, as reported by the provided function, otherwise the "content" template, e.g.
+// "content.html", so that views which need no layout, such as those emitting json, can omit the
+// base template. The function makes it usable with both html and text templates.
+func entryTemplateName(defined func(name string) bool) string {
+	if defined(baseTemplateName()) {
+		return baseTemplateName()
+	}
+
+	return "content" + viewConfig.Extension
+}
+
+// definedIn returns a function reporting whether the named template is defined by t, rather than
+// merely referenced by one of its templates.
+func definedIn(t *template.Template) func(name string) bool {
+	return func(name string) bool {
+		found := t.Lookup(name)
+
+		return found != nil && found.Tree != nil
+	}
 }
 
 // NewController can be used to instantiate a Controller instance.
 func NewController(w http.ResponseWriter, r *http.Request, name string) *Controller {
-	return &Controller{w, r, name, make(map[string]interface{})}
+	return &Controller{ResponseWriter: w, Request: r, Name: name, ViewBag: make(map[string]interface{})}
 }
 
 // funcMap defines a set of additional functions callable within view templates.
@@ -104,14 +249,137 @@ var funcMap = template.FuncMap{
 	"upper": func(x string) string {
 		return strings.ToUpper(x)
 	},
+	// bytes provides a way to output a byte count as a human readable size, in binary units,
+	// e.g. 1536 as "1.5 KB".
+	"bytes": func(n int64) string {
+		return formatBytes(n, 1024)
+	},
+	// bytesSI provides a way to output a byte count as a human readable size, in SI units,
+	// e.g. 1500 as "1.5 kB".
+	"bytesSI": func(n int64) string {
+		return formatBytes(n, 1000)
+	},
+	// plural provides a way to output the singular or plural form of a word for a count,
+	// e.g. {{plural .Count "item" "items"}} or {{plural .Count "%d item" "%d items"}}.
+	"plural": plural,
+	// inline provides a way to output the contents of a css file, within the directory set
+	// via SetAssetRoot, e.g. to inline critical styles.
+	"inline": inlineAsset,
+	// asset provides a way to output the fingerprinted URL of a static asset, as set via
+	// SetAssetManifest, e.g. <link href="{{asset "app.css"}}">.
+	"asset": assetURL,
+}
+
+// sharedViewDir is the directory, within the view root directory, of the templates shared by
+// every view, if set via SetSharedViewDir.
+var sharedViewDir string
+
+// SetSharedViewDir sets the name of a directory, within the view root directory, whose templates,
+// e.g. partials such as a header and footer, are shared by every view, e.g. "shared". The
+// directory does not itself define a view. No directory is shared by default, so that a
+// controller of any name can have views. It must be called before the views are setup.
+func SetSharedViewDir(name string) {
+	sharedViewDir = name
+}
+
+// sharedViewTemplates returns the paths of the templates shared by every view, keyed by name.
+func sharedViewTemplates() (map[string]string, error) {
+	if sharedViewDir == "" {
+		return nil, nil
+	}
+
+	dirname := path.Join(viewRootDir, sharedViewDir)
+
+	list, err := fs.ReadDir(viewConfig.FS, dirname)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	shared := make(map[string]string)
+
+	for _, f := range list {
+		isTemplate, err := path.Match("*"+viewConfig.Extension, f.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !f.IsDir() && isTemplate {
+			shared[f.Name()] = path.Join(dirname, f.Name())
+		}
+	}
+
+	return shared, nil
+}
+
+// ignoredDirs are the patterns of the names of directories skipped when parsing views.
+var ignoredDirs []string
+
+// SetIgnoredDirs sets patterns, as matched by path.Match against the base names of directories,
+// of the directories within the view root directory which are skipped when parsing views, along
+// with their sub directories, e.g. "_*" or ".git". It must be called before the views are setup.
+func SetIgnoredDirs(patterns []string) {
+	ignoredDirs = patterns
+}
+
+// ignoredDir returns whether a directory of the provided name is skipped when parsing views.
+// Malformed patterns match no directory.
+func ignoredDir(name string) bool {
+	for _, pattern := range ignoredDirs {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// viewDelims are the action delimiters set via SetViewDelims, keyed by the path of the directory,
+// relative to the view root directory, of the templates parsed with them.
+var viewDelims = make(map[string][2]string)
+
+// SetViewDelims sets the action delimiters the templates of the view of the named controller are
+// parsed with, in place of "{{" and "}}", e.g. for a config file which itself contains "{{", to be
+// rendered via RenderTextWithDelims. If view is empty, the delimiters apply to the templates of
+// every view of the controller. The templates of the view's sub directories are parsed with the
+// same delimiters, as are those it shares with other views, e.g. the templates of the shared
+// folder set via SetSharedViewDir. It must be called before the views are setup.
+func SetViewDelims(controller, view, left, right string) {
+	viewDelims[path.Join(controller, view)] = [2]string{left, right}
+}
+
+// directoryDelims returns the action delimiters set via SetViewDelims for the templates of a
+// directory, as set for the directory itself or the closest of its parent directories.
+func directoryDelims(dirname string) ([2]string, bool) {
+	rel := dirname
+
+	if viewRootDir != "." {
+		rel = strings.TrimPrefix(strings.TrimPrefix(dirname, viewRootDir), "/")
+	}
+
+	for rel != "." && rel != "" {
+		if delims, ok := viewDelims[rel]; ok {
+			return delims, true
+		}
+
+		rel = path.Dir(rel)
+	}
+
+	return [2]string{}, false
 }
 
 // parseViewDirectory is used to recursively walk a directory and parse the templates within.
 // A given folder defines a view. A view is composed of the templates stored within the
-// root view folder down to the sub folder which defines the view.
+// root view folder down to the sub folder which defines the view, along with the shared
+// templates of the folder of the root view folder set via SetSharedViewDir.
 // For a given view, Templates in subfolders override templates with the
-// same name in a parent folder.
-func parseViewDirectory(dirname string, parentViews map[string]string) error {
+// same name in a parent folder, which override shared templates of the same name.
+func parseViewDirectory(parsed map[string]*template.Template, files map[string][]string, dirname string, shared, parentViews map[string]string) error {
 	views := make(map[string]string)
 
 	if parentViews != nil {
@@ -120,15 +388,7 @@ func parseViewDirectory(dirname string, parentViews map[string]string) error {
 		}
 	}
 
-	f, err := os.Open(dirname)
-
-	if err != nil {
-		return err
-	}
-
-	defer f.Close()
-
-	list, err := f.Readdir(-1)
+	list, err := fs.ReadDir(viewConfig.FS, dirname)
 
 	if err != nil {
 		return err
@@ -136,13 +396,13 @@ func parseViewDirectory(dirname string, parentViews map[string]string) error {
 
 	for _, f := range list {
 
-		isHtml, err := path.Match("*.html", f.Name())
+		isTemplate, err := path.Match("*"+viewConfig.Extension, f.Name())
 
 		if err != nil {
 			return err
 		}
 
-		if !f.IsDir() && isHtml {
+		if !f.IsDir() && isTemplate {
 			// this will override templates stored in parent views
 			views[f.Name()] = path.Join(dirname, f.Name())
 		}
@@ -150,36 +410,207 @@ func parseViewDirectory(dirname string, parentViews map[string]string) error {
 
 	for _, f := range list {
 
-		if f.IsDir() {
-			parseViewDirectory(path.Join(dirname, f.Name()), views)
+		if f.IsDir() && !(dirname == viewRootDir && sharedViewDir != "" && f.Name() == sharedViewDir) && !ignoredDir(f.Name()) {
+			if err := parseViewDirectory(parsed, files, path.Join(dirname, f.Name()), shared, views); err != nil {
+				return err
+			}
 		}
 	}
 
+	// only folders with templates of their own, or of a parent folder, define a view
 	if len(views) > 0 {
-		htmlTemplates := make([]string, len(views))
-
-		i := 0
+		viewTemplates := make([]string, 0, len(views)+len(shared))
 
 		for _, v := range views {
-			htmlTemplates[i] = v
-			i++
+			viewTemplates = append(viewTemplates, v)
+		}
+
+		for name, v := range shared {
+			if _, ok := views[name]; !ok {
+				viewTemplates = append(viewTemplates, v)
+			}
+		}
+
+		t := template.New(baseTemplateName()).Funcs(funcMap).Funcs(requestFuncMap(nil)).Funcs(viewConfig.Funcs)
+
+		if delims, ok := directoryDelims(dirname); ok {
+			t = t.Delims(delims[0], delims[1])
+		}
+
+		t, err := t.ParseFS(viewConfig.FS, viewTemplates...)
+
+		if err != nil {
+			return err
+		}
+
+		parsed[dirname] = t
+		files[dirname] = viewTemplates
+	}
+
+	return nil
+}
+
+// afterRenderSteps are the post-processing steps provided by this package, called in order
+// with the output of each rendered view, before the function set via SetAfterRender.
+var afterRenderSteps = []func(c *Controller, body []byte) []byte{
+	injectCSRFMetaTag,
+	injectDebugToolbar,
+}
+
+// afterRender, if set, is called with the output of each rendered view before it is written.
+var afterRender func(c *Controller, body []byte) []byte
+
+// SetAfterRender sets a function called with the output of each rendered view before it is
+// written to the response, e.g. to inject content or record metrics. The returned slice is
+// written in place of the rendered output; returning body unchanged leaves the output as is.
+func SetAfterRender(fn func(c *Controller, body []byte) []byte) {
+	afterRender = fn
+}
+
+var activeClass = "active"
+
+// SetActiveClass sets the class provided by the "activeClass" view template function, "active" by default.
+func SetActiveClass(class string) {
+	activeClass = class
+}
+
+// requestFuncMap defines the functions callable within view templates whose results
+// depend on the request being served. At parse time these are registered with a nil
+// controller, so that the templates are aware of them, and rebound per request in render.
+func requestFuncMap(c *Controller) template.FuncMap {
+	return template.FuncMap{
+		// nonce provides the per request nonce, as referenced by the
+		// Content-Security-Policy header set via SetCSPNonceHeader.
+		"nonce": func() string {
+			return c.Nonce()
+		},
+		// csrf provides the request's CSRF token, to be submitted with forms
+		// via a field named as csrfFieldName.
+		"csrf": func() string {
+			return c.CSRFToken()
+		},
+		// breadcrumbs provides the request's breadcrumb trail, as pushed via PushBreadcrumb.
+		"breadcrumbs": func() []Breadcrumb {
+			return c.Breadcrumbs()
+		},
+		// requestID provides the identifier of the request, as returned by RequestID.
+		"requestID": func() string {
+			return c.RequestID()
+		},
+		// feature provides whether the named feature flag is enabled for the request, as
+		// returned by FeatureEnabled, e.g. {{if feature "new-ui"}}.
+		"feature": func(name string) bool {
+			return c.FeatureEnabled(name)
+		},
+		// eqQuery provides whether the request's URL query value of the provided query parameter
+		// equals the provided value, e.g. {{if eqQuery "tab" "profile"}} to highlight a tab.
+		// A query parameter without a value never equals a value.
+		"eqQuery": func(queryParam, value string) bool {
+			values := c.GetStringSlice(queryParam)
+
+			return len(values) > 0 && values[0] == value
+		},
+		// activeClass provides the class set via SetActiveClass if the view being rendered
+		// is of the provided controller and, if provided, action, otherwise an empty string.
+		// This can be used to highlight the navigation link of the current page.
+		"activeClass": func(controller string, action ...string) string {
+			v := c.view
+
+			if v == nil || v.Controller != controller || (len(action) > 0 && v.Action != action[0]) {
+				return ""
+			}
+
+			return activeClass
+		},
+	}
+}
+
+// resolvedTemplate is the result of looking up the templates for a view.
+type resolvedTemplate struct {
+	t    *template.Template
+	name string
+}
+
+// resolvedTemplates caches the templates found for a view, keyed by "[controller]/[view]".
+// It is guarded by templatesMutex and reset whenever the views are reparsed.
+var resolvedTemplates map[string]resolvedTemplate
+
+// findTemplate looks up the templates for a view, by convention using the path
+// "[view root dir]/[controller]/[view]", falling back to the templates shared by the
+// controller, then to those shared by all controllers and finally to those of the view
+// set via SetFallbackView. The name of the templates
+// looked up last is returned, whether found or not. Templates found are cached, so
+// that subsequent lookups for the same view skip the fallbacks.
+func findTemplate(controllerName, view string) (*template.Template, string, bool) {
+	key := controllerName + "/" + view
+
+	templatesMutex.RLock()
+	r, ok := resolvedTemplates[key]
+	templatesMutex.RUnlock()
+
+	if ok {
+		return r.t, r.name, true
+	}
+
+	templatesMutex.Lock()
+	defer templatesMutex.Unlock()
+
+	t, name, ok := resolveTemplate(controllerName, view)
+
+	if ok {
+		resolvedTemplates[key] = resolvedTemplate{t, name}
+	}
+
+	return t, name, ok
+}
+
+// WarmUp populates the cache of the templates found for each view, for every view
+// directory parsed, so that the first requests served do not incur the cost of lookups.
+func WarmUp() error {
+	if viewConfig == nil {
+		return errors.New("Views must be setup before being warmed up.")
+	}
+
+	templatesMutex.RLock()
+
+	names := make([]string, 0, len(templates))
+
+	for name := range templates {
+		names = append(names, name)
+	}
+
+	templatesMutex.RUnlock()
+
+	for _, name := range names {
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, viewRootDir), "/")
+
+		if viewRootDir == "." {
+			rel = name
 		}
 
-		t := template.New("base.html").Funcs(funcMap)
+		parts := strings.SplitN(rel, "/", 2)
 
-		templates[dirname] = template.Must(t.ParseFiles(htmlTemplates...))
+		if len(parts) < 2 {
+			continue
+		}
+
+		if _, name, ok := findTemplate(parts[0], parts[1]); !ok {
+			return fmt.Errorf("The templates for %v were not found.", name)
+		}
 	}
 
 	return nil
 }
 
-func render(w http.ResponseWriter, controllerName, view string, vm interface{}) {
-	name := fmt.Sprintf("%s/%s/%s", viewRootDir, controllerName, view)
+// resolveTemplate looks up the templates for a view as described by findTemplate, without
+// consulting the cache. The caller must hold templatesMutex.
+func resolveTemplate(controllerName, view string) (*template.Template, string, bool) {
+	name := path.Join(viewRootDir, controllerName, view)
 
 	t, ok := templates[name]
 
 	if !ok {
-		name = fmt.Sprintf("%s/%s", viewRootDir, controllerName)
+		name = path.Join(viewRootDir, controllerName)
 
 		t, ok = templates[name]
 	}
@@ -190,43 +621,643 @@ func render(w http.ResponseWriter, controllerName, view string, vm interface{})
 		t, ok = templates[name]
 	}
 
+	if !ok && fallbackView != "" {
+		name = path.Join(viewRootDir, fallbackController, fallbackView)
+
+		t, ok = templates[name]
+	}
+
+	return t, name, ok
+}
+
+var fallbackController, fallbackView string
+
+// SetFallbackView sets a view, e.g. a generic "coming soon" page, whose templates are rendered
+// in place of those of any view for which none are found, i.e. when the view, its controller
+// and the view root directory all lack templates. The templates are looked up using the path
+// "[view root dir]/[controller]/[view]", without any further fallback. An empty view unsets it.
+func SetFallbackView(controller, view string) {
+	templatesMutex.Lock()
+	defer templatesMutex.Unlock()
+
+	fallbackController, fallbackView = controller, view
+
+	if resolvedTemplates != nil {
+		resolvedTemplates = make(map[string]resolvedTemplate)
+	}
+}
+
+// viewTemplate returns the templates of a view, ready to be executed for the request.
+func viewTemplate(c *Controller, controllerName, view string) (*template.Template, error) {
+	if viewConfig != nil && viewConfig.DevMode {
+		if err := parseViews(); err != nil {
+			return nil, err
+		}
+	}
+
+	t, name, ok := findTemplate(controllerName, view)
+
 	if !ok {
-		http.Error(w, fmt.Sprintf("The templates for %v were not found.", name), http.StatusInternalServerError)
+		return nil, fmt.Errorf("The templates for %v were not found.", name)
+	}
+
+	c.viewPath = name
+
+	// The parsed templates are never executed directly, as html/template does not
+	// allow a template to be cloned once executed. Each render works on a clone
+	// with the request specific functions bound.
+	t, err := t.Clone()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Funcs(requestFuncMap(c)), nil
+}
+
+func render(c *Controller, controllerName, view string, vm interface{}) {
+	body, err := renderBytes(c, controllerName, view, vm)
+
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	err := t.ExecuteTemplate(w, "base.html", vm)
+	writeRendered(c, body)
+}
+
+// renderBytes executes the templates of a view, returning the output.
+func renderBytes(c *Controller, controllerName, view string, vm interface{}) ([]byte, error) {
+	return renderBytesWithFuncs(c, controllerName, view, vm, nil)
+}
+
+// renderBytesWithFuncs executes the templates of a view with the provided functions added to, or
+// overriding, those of the view template functions, returning the output.
+func renderBytesWithFuncs(c *Controller, controllerName, view string, vm interface{}, funcs template.FuncMap) ([]byte, error) {
+	start := time.Now()
+
+	defer func() {
+		c.renderDuration = time.Since(start)
+	}()
+
+	t, err := viewTemplate(c, controllerName, view)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if funcs != nil {
+		t = t.Funcs(funcs)
+	}
+
+	// The view is rendered to a buffer, so that an error part way through execution
+	// does not result in a partially written response.
+	var buf bytes.Buffer
+
+	err = executeTemplate(&buf, t, entryTemplateName(definedIn(t)), vm)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeRendered post-processes the output of a rendered view, then writes it to the response.
+func writeRendered(c *Controller, body []byte) {
+	for _, step := range afterRenderSteps {
+		body = step(c, body)
+	}
+
+	if afterRender != nil {
+		body = afterRender(c, body)
+	}
+
+	writeBody(c, body)
+}
+
+// isHtml returns whether the response is html, as per its Content-Type header if set,
+// otherwise as detected from the body.
+func isHtml(c *Controller, body []byte) bool {
+	contentType := c.ResponseWriter.Header().Get("Content-Type")
+
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	return strings.HasPrefix(contentType, "text/html")
+}
+
+// writeBody writes the rendered output of a view to the response, compressing it and
+// tagging it with an ETag when enabled.
+func writeBody(c *Controller, body []byte) {
+	w := c.ResponseWriter
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(body))
+	}
+
+	gzipped := gzipEnabled && acceptsGzip(c.Request)
+
+	if gzipEnabled {
+		c.AddVary("Accept-Encoding")
+	}
+
+	if etagsEnabled {
+		etag := computeETag(body, gzipped)
+
+		w.Header().Set("ETag", etag)
+
+		if etagMatches(c.Request, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if gzipped {
+		w.Header().Set("Content-Encoding", "gzip")
+
+		body = gzipBytes(body)
+	}
+
+	c.writeContent(0, body)
+}
+
+// templateExecutor is implemented by both html and text templates.
+type templateExecutor interface {
+	ExecuteTemplate(w io.Writer, name string, data interface{}) error
+}
+
+// executeTemplate executes the named template, recovering from any panic raised during
+// execution, so that a single failing render cannot take down the process.
+func executeTemplate(w io.Writer, t templateExecutor, name string, data interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("mvc: panic executing template %v: %v", name, r)
+
+			err = fmt.Errorf("Executing template %v panicked: %v", name, r)
+		}
+	}()
+
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// newView creates the View passed to the templates of a view rendered by the controller.
+func (c *Controller) newView(controllerName, view string, viewModel interface{}) *View {
+	return c.newViewWithData(controllerName, view, viewModel, nil)
+}
+
+// newViewWithData creates the View passed to the templates of a view rendered by the controller,
+// with a Bag merging, in increasing order of precedence, the global view data, the data of the
+// view data providers, the values set by the view transformers, the controller's ViewBag and the
+// provided per-render data.
+func (c *Controller) newViewWithData(controllerName, view string, viewModel interface{}, data map[string]interface{}) *View {
+	action := c.Action
+
+	if action == "" {
+		action = view
+	}
+
+	bag := make(map[string]interface{})
+
+	mergeInto(bag, globalViewData())
+
+	for _, provide := range viewDataProviders {
+		mergeInto(bag, provide(c))
+	}
+
+	// the transformers see the entries of the controller and per-render layers, but cannot
+	// override them, so they are merged before the transformers run and again after
+	mergeInto(bag, c.ViewBag)
+	mergeInto(bag, data)
+
+	c.view = &View{Controller: controllerName, Action: action, Name: view, Bag: bag, Model: viewModel}
+
+	if len(viewTransformers) > 0 {
+		for _, transform := range viewTransformers {
+			transform(c.view)
+		}
+
+		mergeInto(c.view.Bag, c.ViewBag)
+		mergeInto(c.view.Bag, data)
+	}
+
+	if c.view.Title == "" {
+		c.view.Title, _ = c.view.Bag["title"].(string)
 	}
+
+	return c.view
+}
+
+var viewTransformers []func(v *View)
+
+// SetViewTransformer adds functions to adjust every View before the templates of the view are
+// executed with it, e.g. to add a computed value to its Bag. Transformers are called in the order
+// added, those of earlier calls first. Entries of the controller's ViewBag and per-render data
+// take precedence over the values they set.
+func SetViewTransformer(fns ...func(v *View)) {
+	viewTransformers = append(viewTransformers, fns...)
+}
+
+// ResetViewTransformers removes the transformers added via SetViewTransformer.
+func ResetViewTransformers() {
+	viewTransformers = nil
 }
 
 // RenderViewModel has the same functionality as Render, as well as the ability
 // to pass along a viewModel to the templates associated with the view.
 func (c *Controller) RenderViewModel(view string, viewModel interface{}) {
-	v := &View{c.Name, view, c.ViewBag, viewModel}
+	v := c.newView(c.Name, view, viewModel)
 
 	render(c, c.Name, view, v)
 }
 
+// RenderWithFuncs has the same functionality as RenderViewModel, as well as the ability to add
+// or override view template functions for this render only, e.g. a fixed clock for a snapshot.
+// Functions overridden must be defined when the templates are parsed, e.g. via
+// SetupViewsConfig.Funcs. The templates are executed on a clone, leaving the parsed templates
+// unaffected.
+func (c *Controller) RenderWithFuncs(view string, viewModel interface{}, funcs template.FuncMap) {
+	body, err := renderBytesWithFuncs(c, c.Name, view, c.newView(c.Name, view, viewModel), funcs)
+
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeRendered(c, body)
+}
+
+// RenderParts executes each of the named templates of a view in turn, with the provided model,
+// returning their outputs rather than writing them to the response, e.g. to render the html
+// and text parts of an email. The templates of the view are looked up as by Render.
+func (c *Controller) RenderParts(view string, parts []string, viewModel interface{}) ([][]byte, error) {
+	t, err := viewTemplate(c, c.Name, view)
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := c.newView(c.Name, view, viewModel)
+
+	outputs := make([][]byte, len(parts))
+
+	for i, part := range parts {
+		var buf bytes.Buffer
+
+		if err := executeTemplate(&buf, t, part, v); err != nil {
+			return nil, err
+		}
+
+		outputs[i] = buf.Bytes()
+	}
+
+	return outputs, nil
+}
+
+// RenderToBuffer renders a view of the named controller, as rendered by the named action with the
+// provided model, returning the output rather than writing it to a response, e.g. so that tests
+// of an application can assert on the output of its views without a request. The view is rendered
+// for a GET request of "/", whose response is discarded. The output is not post-processed, e.g.
+// by the after render hook.
+func RenderToBuffer(controller, action, view string, model interface{}) (*bytes.Buffer, error) {
+	r, err := http.NewRequest("GET", "/", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := NewController(discardResponseWriter{make(http.Header)}, r, controller)
+
+	c.Action = action
+
+	body, err := renderBytes(c, controller, view, c.newView(controller, view, model))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewBuffer(body), nil
+}
+
+// discardResponseWriter is an http.ResponseWriter which discards the response.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header { return w.header }
+
+func (w discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (w discardResponseWriter) WriteHeader(int) {}
+
+// RenderStreaming has the same functionality as RenderViewModel, except that the view is
+// executed directly against the response, rather than first being rendered to a buffer.
+// This avoids holding the whole output of very large views in memory, at the cost of an
+// error part way through execution leaving a partially written response, as the status
+// will already have been sent. For the same reason the view's output is not passed to the
+// after render hook, nor compressed or tagged with an ETag.
+func (c *Controller) RenderStreaming(view string, viewModel interface{}) {
+	t, err := viewTemplate(c, c.Name, view)
+
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	w := &trackingWriter{Writer: c.ResponseWriter}
+
+	err = executeTemplate(w, t, entryTemplateName(definedIn(t)), c.newView(c.Name, view, viewModel))
+
+	if err != nil {
+		log.Printf("mvc: error streaming view %v of controller %v: %v", view, c.Name, err)
+
+		if !w.written {
+			renderError(c, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// trackingWriter is an io.Writer which tracks whether anything has been written to it.
+type trackingWriter struct {
+	io.Writer
+	written bool
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.written = w.written || len(b) > 0
+
+	return w.Writer.Write(b)
+}
+
 // Render by convention uses the path "[view root dir]/[controller]/[view]" to lookup
 // a view to render. A view is rendered by executing the base.html template
-// associated with that view.
+// associated with that view, or its content.html template if it has no base.html.
 func (c *Controller) Render(view string) {
 	c.RenderViewModel(view, nil)
 }
 
+var jsonEscapeHTML = true
+
+// SetJSONEscapeHTML sets whether the characters <, > and & are escaped within json strings
+// written by JsonContent and its variants, as they are by default, so that the json can be
+// safely embedded in html.
+func SetJSONEscapeHTML(escape bool) {
+	jsonEscapeHTML = escape
+}
+
 // JsonContent can be used to write to the response, the provided model, as json.
 func (c *Controller) JsonContent(model interface{}) {
+	c.writeJson(0, model)
+}
+
+// JsonContentFields can be used to write to the response, the provided model, as json, with only
+// the provided fields of the resulting object, e.g. as requested via "?fields=id,name". Only
+// top-level fields are filtered. If no fields are provided, or the model is not encoded as a
+// json object, the model is written in full, as by JsonContent.
+func (c *Controller) JsonContentFields(model interface{}, fields []string) {
+	if len(fields) == 0 {
+		c.JsonContent(model)
+		return
+	}
+
+	b, err := encodeJSON(model)
+
+	if err != nil {
+		c.JsonContent(model)
+		return
+	}
+
+	var object map[string]json.RawMessage
+
+	if err := json.Unmarshal(b, &object); err != nil || object == nil {
+		c.JsonContent(model)
+		return
+	}
+
+	selected := make(map[string]json.RawMessage)
+
+	for _, field := range fields {
+		if v, ok := object[field]; ok {
+			selected[field] = v
+		}
+	}
+
+	c.JsonContent(selected)
+}
+
+// JsonCollection can be used to write to the response, the provided collection and its metadata,
+// as a json envelope, e.g. {"data":[...],"meta":{"total":20,"page":2}}. A nil slice is written as
+// an empty array, and nil metadata as an empty object, so that clients need not handle null.
+func (c *Controller) JsonCollection(data interface{}, meta map[string]interface{}) {
+	if v := reflect.ValueOf(data); !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		data = []interface{}{}
+	}
+
+	if meta == nil {
+		meta = map[string]interface{}{}
+	}
+
+	c.JsonContent(struct {
+		Data interface{}            `json:"data"`
+		Meta map[string]interface{} `json:"meta"`
+	}{data, meta})
+}
+
+// writeJson writes the provided model to the response as json, with the provided status,
+// or the status already written if 0.
+func (c *Controller) writeJson(status int, model interface{}) {
 	c.ResponseWriter.Header().Set("Content-Type", "application/javascript")
-	json.NewEncoder(c.ResponseWriter).Encode(model)
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	enc.SetEscapeHTML(jsonEscapeHTML)
+	enc.Encode(model)
+
+	c.writeContent(status, buf.Bytes())
 }
 
 // TextContent can be used to write to the response, the provided text.
 func (c *Controller) TextContent(text string) {
-	c.ResponseWriter.Header().Set("Content-Type", "text/plain")
-	fmt.Fprintf(c.ResponseWriter, "%v", text)
+	c.writeText("text/plain", text)
+}
+
+// HtmlContent can be used to write to the response, the provided html.
+func (c *Controller) HtmlContent(html string) {
+	c.writeText("text/html", html)
+}
+
+// BytesContent can be used to write to the response, the provided bytes, as the provided content
+// type. As browsers may otherwise sniff the type of the content, e.g. treating bytes uploaded as
+// an image as html, the X-Content-Type-Options header is set to nosniff. An error is returned,
+// without writing to the response, if contentType is empty.
+func (c *Controller) BytesContent(contentType string, b []byte) error {
+	if contentType == "" {
+		return errors.New("A content type is required.")
+	}
+
+	c.ResponseWriter.Header().Set("Content-Type", contentType)
+	c.ResponseWriter.Header().Set("X-Content-Type-Options", "nosniff")
+
+	c.writeContent(0, b)
+
+	return nil
+}
+
+// writeContent writes the provided status, unless 0, and body to the response, reporting
+// the length of the body via the Content-Length header. For HEAD requests the body is
+// discarded, with only its length reported.
+func (c *Controller) writeContent(status int, body []byte) {
+	c.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	if status != 0 {
+		c.ResponseWriter.WriteHeader(status)
+	}
+
+	if c.Request.Method == "HEAD" {
+		return
+	}
+
+	c.ResponseWriter.Write(body)
+}
+
+// Created responds with a 201 Created status, and a Location header referencing the created resource.
+func (c *Controller) Created(location string) {
+	c.ResponseWriter.Header().Set("Location", location)
+	c.ResponseWriter.WriteHeader(http.StatusCreated)
+}
+
+// CreatedJSON responds with a 201 Created status, and a Location header referencing the
+// created resource, writing the provided model to the response as json.
+func (c *Controller) CreatedJSON(location string, model interface{}) {
+	c.ResponseWriter.Header().Set("Location", location)
+	c.writeJson(http.StatusCreated, model)
+}
+
+// IsAjax returns whether the request was made via XMLHttpRequest, as indicated by the
+// X-Requested-With header set by most javascript libraries.
+func (c *Controller) IsAjax() bool {
+	return c.Request.Header.Get("X-Requested-With") == "XMLHttpRequest"
+}
+
+// MergeBag copies the entries of other into the ViewBag, e.g. when composing a view from the
+// bags of a parent and child controller. Entries whose keys are already in the ViewBag are
+// replaced if overwrite is true, otherwise they are left as they are.
+func (c *Controller) MergeBag(other map[string]interface{}, overwrite bool) {
+	for k, v := range other {
+		if _, ok := c.ViewBag[k]; ok && !overwrite {
+			continue
+		}
+
+		c.ViewBag[k] = v
+	}
+}
+
+// botPatterns are the substrings of the User-Agent headers of bots, in lower case.
+var botPatterns = []string{
+	"googlebot", "bingbot", "slurp", "duckduckbot", "baiduspider", "yandexbot",
+	"facebookexternalhit", "twitterbot", "linkedinbot", "applebot", "bot", "crawler", "spider",
+}
+
+// SetBotPatterns sets the substrings of the User-Agent headers identifying bots, such as search
+// engine crawlers, as matched case-insensitively by IsBot, in place of the default patterns.
+func SetBotPatterns(patterns []string) {
+	lowered := make([]string, len(patterns))
+
+	for i, p := range patterns {
+		lowered[i] = strings.ToLower(p)
+	}
+
+	botPatterns = lowered
+}
+
+// IsBot returns whether the request was made by a bot, such as a search engine crawler, as
+// indicated by its User-Agent header containing any of the patterns set via SetBotPatterns.
+func (c *Controller) IsBot() bool {
+	userAgent := strings.ToLower(c.Request.UserAgent())
+
+	for _, p := range botPatterns {
+		if p != "" && strings.Contains(userAgent, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RespondCreated responds to a request which created a resource. Ajax requests receive
+// a 201 Created status with the provided model as json, other requests are redirected
+// to location with a 303 See Other status.
+func (c *Controller) RespondCreated(location string, model interface{}) {
+	if c.IsAjax() {
+		c.writeJson(http.StatusCreated, model)
+		return
+	}
+
+	http.Redirect(c.ResponseWriter, c.Request, location, http.StatusSeeOther)
+}
+
+// Abort writes the provided status and message to the response and marks the request as
+// aborted, so that no subsequent filters or the action are run by the Router.
+func (c *Controller) Abort(status int, message string) {
+	c.aborted = true
+
+	http.Error(c.ResponseWriter, message, status)
+}
+
+// Aborted returns whether the request has been aborted via Abort.
+func (c *Controller) Aborted() bool {
+	return c.aborted
+}
+
+// BagString returns the ViewBag value associated with the provided key as a string.
+// If the key is absent, or its value is not a string, the provided default value is returned.
+func (c *Controller) BagString(key string, def string) string {
+	if v, ok := c.ViewBag[key].(string); ok {
+		return v
+	}
+
+	return def
+}
+
+// BagInt returns the ViewBag value associated with the provided key as an int.
+// If the key is absent, or its value is not an int, the provided default value is returned.
+func (c *Controller) BagInt(key string, def int) int {
+	if v, ok := c.ViewBag[key].(int); ok {
+		return v
+	}
+
+	return def
+}
+
+// BagBool returns the ViewBag value associated with the provided key as a bool.
+// If the key is absent, or its value is not a bool, the provided default value is returned.
+func (c *Controller) BagBool(key string, def bool) bool {
+	if v, ok := c.ViewBag[key].(bool); ok {
+		return v
+	}
+
+	return def
+}
+
+// QueryMap returns the URL query parameters as a map, with the first value associated with each parameter.
+func (c *Controller) QueryMap() map[string]string {
+	m := make(map[string]string)
+
+	for k, v := range c.Request.URL.Query() {
+		if len(v) > 0 {
+			m[k] = v[0]
+		}
+	}
+
+	return m
+}
+
+// QueryMapSlice returns the URL query parameters as a map, with all values associated with each parameter.
+func (c *Controller) QueryMapSlice() map[string][]string {
+	return map[string][]string(c.Request.URL.Query())
 }
 
 // GetStringSlice returns the URL query values associated with the provided query parameter as a slice of strings.
@@ -234,6 +1265,26 @@ func (c *Controller) GetStringSlice(queryParam string) []string {
 	return c.Request.URL.Query()[queryParam]
 }
 
+// GetIndexedSlice returns the URL query values associated with the indexed query parameters
+// "[prefix][0]", "[prefix][1]" and so on as a slice of strings, in index order. Collection
+// stops at the first missing index, e.g. for "f[0]=a&f[1]=b&f[3]=d" the values of "f" are
+// ["a", "b"].
+func (c *Controller) GetIndexedSlice(prefix string) []string {
+	query := c.Request.URL.Query()
+
+	var values []string
+
+	for i := 0; ; i++ {
+		v, ok := query[prefix+"["+strconv.Itoa(i)+"]"]
+
+		if !ok || len(v) == 0 {
+			return values
+		}
+
+		values = append(values, v[0])
+	}
+}
+
 // GetString returns the URL query value associated with the provided query parameter as a string.
 // If the provided query parameter does not have a value associated with it, the provided default value is returned.
 func (c *Controller) GetString(queryParam string, def string) string {
@@ -271,3 +1322,114 @@ func (c *Controller) GetInt64(queryParam string, def int64) int64 {
 func (c *Controller) GetInt(queryParam string, def int64) int {
 	return int(c.GetInt64(queryParam, def))
 }
+
+// GetIntSlice returns the URL query values associated with the provided query parameter as a slice of int64s.
+// Values which are not parsable as numeric are omitted.
+func (c *Controller) GetIntSlice(queryParam string) []int64 {
+	var ints []int64
+
+	for _, s := range c.GetStringSlice(queryParam) {
+		i, err := strconv.ParseInt(s, 10, 64)
+
+		if err == nil {
+			ints = append(ints, i)
+		}
+	}
+
+	return ints
+}
+
+// GetIntSliceDefault returns the URL query values associated with the provided query parameter as a slice
+// of int64s, as GetIntSlice does. If none of the values are parsable as numeric, or the provided query
+// parameter does not have a value associated with it, the provided default values are returned.
+func (c *Controller) GetIntSliceDefault(queryParam string, def []int64) []int64 {
+	ints := c.GetIntSlice(queryParam)
+
+	if len(ints) == 0 {
+		return def
+	}
+
+	return ints
+}
+
+// GetIP returns the URL query value associated with the provided query parameter as an IP address,
+// in either IPv4 or IPv6 form. If the provided query parameter does not have a value associated
+// with it, or if the value is not parsable as an IP address, the provided default value is returned.
+func (c *Controller) GetIP(queryParam string, def netip.Addr) netip.Addr {
+	addr, err := netip.ParseAddr(c.GetString(queryParam, ""))
+
+	if err != nil {
+		return def
+	}
+
+	return addr
+}
+
+// GetUUID returns the URL query value associated with the provided query parameter as a lowercase UUID,
+// and whether the value is a UUID in the canonical 8-4-4-4-12 hexadecimal form. If the provided query
+// parameter does not have a value associated with it, or if the value is not a UUID, ok is false.
+func (c *Controller) GetUUID(queryParam string) (uuid string, ok bool) {
+	s := c.GetString(queryParam, "")
+
+	if len(s) != 36 {
+		return "", false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if s[i] != '-' {
+				return "", false
+			}
+		case '0' <= s[i] && s[i] <= '9', 'a' <= s[i] && s[i] <= 'f', 'A' <= s[i] && s[i] <= 'F':
+		default:
+			return "", false
+		}
+	}
+
+	return strings.ToLower(s), true
+}
+
+// SortField is a field of a sort specification, as parsed by ParseSort.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort parses the URL query value associated with the provided query parameter as a sort
+// specification of comma separated fields, each prefixed by "-" if sorted in descending order,
+// e.g. "-created,name" sorts by created descending then name ascending. An error is returned for
+// any field not of those allowed. If the query parameter does not have a value associated with
+// it, no fields are returned.
+func (c *Controller) ParseSort(queryParam string, allowed []string) ([]SortField, error) {
+	s := c.GetString(queryParam, "")
+
+	if s == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		f := SortField{Field: strings.TrimPrefix(part, "-"), Descending: strings.HasPrefix(part, "-")}
+
+		ok := false
+
+		for _, a := range allowed {
+			if f.Field == a {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("Cannot sort by %q.", f.Field)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
@@ -19,23 +19,30 @@ package mvc
 import (
 	"bytes"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
-func createTemplateFile(dir, name, content string, t *testing.T) {
+func createTemplateFile(dir, name, content string, t testing.TB) {
 	template, err := os.Create(path.Join(dir, name))
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	} else {
 		defer template.Close()
 	}
 
-	fmt.Fprintf(template, content)
+	fmt.Fprint(template, content)
 }
 
 func createFolder(dir, name string, t *testing.T) string {
@@ -44,12 +51,47 @@ func createFolder(dir, name string, t *testing.T) string {
 	err := os.Mkdir(newDir, 0700)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	return newDir
 }
 
+// setupTestViews creates a temporary view root directory populated with the provided
+// templates, keyed by their path relative to the root, and parses it via SetupViews.
+func setupTestViews(files map[string]string, t testing.TB) string {
+	root, err := ioutil.TempDir("", "mvc_test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		dir := path.Join(root, path.Dir(name))
+
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		createTemplateFile(dir, path.Base(name), content, t)
+	}
+
+	viewConfig = nil
+
+	if err := SetupViews(root); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+// recordingController returns a controller for the named controller which records its response.
+func recordingController(name string, r *http.Request) (*Controller, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+
+	return NewController(w, r, name), w
+}
+
 func mockController(name string) *Controller {
 	w := &mockResponseWriter{}
 
@@ -113,6 +155,8 @@ func TestViewTemplates(t *testing.T) {
 
 	createTemplateFile(aIndexActionDir, "content.html", `level`, t)
 
+	viewConfig = nil
+
 	SetupViews(root)
 
 	type testCase struct {
@@ -154,3 +198,1157 @@ func (w *mockResponseWriter) Write(b []byte) (int, error) { return w.buffer.Writ
 func (w *mockResponseWriter) WriteHeader(int) {}
 
 func (w *mockResponseWriter) Body() []byte { return w.buffer.Bytes() }
+
+func TestSetupViewsWithConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"site/views/base.tmpl":               {Data: []byte(`{{shout "hello"}} {{template "content.tmpl" .}}`)},
+		"site/views/home/index/content.tmpl": {Data: []byte(`{{.Model}}`)},
+		"site/views/home/index/ignored.html": {Data: []byte(`{{undefined}}`)},
+	}
+
+	viewConfig = nil
+
+	err := SetupViewsWithConfig(SetupViewsConfig{
+		FS:        fsys,
+		Root:      "site/views",
+		Extension: ".tmpl",
+		Funcs: template.FuncMap{
+			"shout": func(s string) string { return strings.ToUpper(s) + "!" },
+		},
+		DevMode: true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := mockController("home")
+
+	c.RenderViewModel("index", "world")
+
+	if string(c.ResponseWriter.(*mockResponseWriter).Body()) != "HELLO! world" {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), "HELLO! world")
+	}
+
+	// In dev mode, changes to templates are picked up on the next render.
+
+	fsys["site/views/home/index/content.tmpl"] = &fstest.MapFile{Data: []byte(`changed`)}
+
+	c = mockController("home")
+
+	c.Render("index")
+
+	if string(c.ResponseWriter.(*mockResponseWriter).Body()) != "HELLO! changed" {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), "HELLO! changed")
+	}
+
+	// In dev mode, a template which fails to parse results in an error response, not a panic.
+
+	fsys["site/views/home/index/content.tmpl"] = &fstest.MapFile{Data: []byte(`{{.Model`)}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	rc, w := recordingController("home", r)
+
+	rc.Render("index")
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "content.tmpl") {
+		t.Errorf("Result was %d '%s', expected a %d parse error", w.Code, w.Body.String(), http.StatusInternalServerError)
+	}
+
+	fsys["site/views/home/index/content.tmpl"] = &fstest.MapFile{Data: []byte(`{{.Model}}`)}
+
+	if SetupViewsWithConfig(SetupViewsConfig{Root: "views"}) == nil {
+		t.Errorf("Expected an error setting up views more than once")
+	}
+}
+
+func TestSetupViewsParseError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/base.html":               {Data: []byte(`{{template "content.html" .}}`)},
+		"views/home/index/content.html": {Data: []byte(`{{if}}`)},
+	}
+
+	viewConfig = nil
+
+	if err := SetupViewsWithConfig(SetupViewsConfig{FS: fsys, Root: "views"}); err == nil {
+		t.Fatalf("Expected an error for a template which fails to parse")
+	}
+
+	fsys["views/home/index/content.html"] = &fstest.MapFile{Data: []byte(`fixed`)}
+
+	if err := SetupViewsWithConfig(SetupViewsConfig{FS: fsys, Root: "views"}); err != nil {
+		t.Errorf("Expected the views to be setup once fixed, error was %v", err)
+	}
+}
+
+func TestRenderPanicIsolation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/base.html": {Data: []byte(`partial {{explode}}`)},
+	}
+
+	viewConfig = nil
+
+	err := SetupViewsWithConfig(SetupViewsConfig{
+		FS:   fsys,
+		Root: "views",
+		Funcs: template.FuncMap{
+			"explode": func() string { panic("boom") },
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.Render("index")
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+
+	if strings.Contains(w.Body.String(), "partial") {
+		t.Errorf("Expected no partially rendered output, got '%s'", w.Body.String())
+	}
+}
+
+func TestAfterRender(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Controller}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetAfterRender(func(c *Controller, body []byte) []byte {
+		return append(body, []byte("<!-- "+c.Name+" -->")...)
+	})
+
+	defer SetAfterRender(nil)
+
+	c := mockController("home")
+
+	c.Render("index")
+
+	expected := "<p>home</p><!-- home -->"
+
+	if string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestQueryMap(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/?a=1&b=2&b=3", nil)
+
+	c := NewController(&mockResponseWriter{}, r, "home")
+
+	m := c.QueryMap()
+
+	if len(m) != 2 || m["a"] != "1" || m["b"] != "2" {
+		t.Errorf("Result was %v, expected map[a:1 b:2]", m)
+	}
+
+	ms := c.QueryMapSlice()
+
+	if len(ms) != 2 || len(ms["a"]) != 1 || len(ms["b"]) != 2 || ms["b"][1] != "3" {
+		t.Errorf("Result was %v, expected map[a:[1] b:[2 3]]", ms)
+	}
+
+	c = mockController("home")
+
+	if c.QueryMap() == nil || len(c.QueryMap()) != 0 || c.QueryMapSlice() == nil || len(c.QueryMapSlice()) != 0 {
+		t.Errorf("Expected empty maps for an empty query")
+	}
+}
+
+func TestHeadRequest(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>hello</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("HEAD", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.Render("index")
+
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "12" || w.Header().Get("Content-Type") == "" {
+		t.Errorf("Result was '%s' with headers %v, expected an empty body with Content-Length 12", w.Body.String(), w.Header())
+	}
+
+	c, w = recordingController("home", r)
+
+	c.JsonContent(map[string]int{"a": 1})
+
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "8" || w.Header().Get("Content-Type") != "application/javascript" {
+		t.Errorf("Result was '%s' with headers %v, expected an empty body with Content-Length 8", w.Body.String(), w.Header())
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	type testCase struct {
+		userAgent string
+		expected  bool
+	}
+
+	testCases := []testCase{
+		testCase{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		testCase{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", true},
+		testCase{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36", false},
+		testCase{"", false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("User-Agent", tc.userAgent)
+
+		c, _ := recordingController("home", r)
+
+		if result := c.IsBot(); result != tc.expected {
+			t.Errorf("%s: result was %v, expected %v", tc.userAgent, result, tc.expected)
+		}
+	}
+
+	defer SetBotPatterns(botPatterns)
+
+	SetBotPatterns([]string{"Prerender"})
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 prerender (+https://github.com/prerender/prerender)")
+
+	if c, _ := recordingController("home", r); !c.IsBot() {
+		t.Errorf("Expected a custom pattern to match case-insensitively")
+	}
+
+	r.Header.Set("User-Agent", "Googlebot/2.1")
+
+	if c, _ := recordingController("home", r); c.IsBot() {
+		t.Errorf("Expected custom patterns to replace the defaults")
+	}
+}
+
+func TestRespondCreated(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/posts", nil)
+	r.Header.Set("X-Requested-With", "XMLHttpRequest")
+
+	c, w := recordingController("post", r)
+
+	c.RespondCreated("/posts/1", map[string]int{"id": 1})
+
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"id\":1}\n" || w.Header().Get("Content-Type") != "application/javascript" {
+		t.Errorf("Result was %d '%s', expected %d '%s'", w.Code, w.Body.String(), http.StatusCreated, "{\"id\":1}\n")
+	}
+
+	r, _ = http.NewRequest("POST", "/posts", nil)
+
+	c, w = recordingController("post", r)
+
+	c.RespondCreated("/posts/1", map[string]int{"id": 1})
+
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/posts/1" {
+		t.Errorf("Result was %d to '%s', expected %d to '%s'", w.Code, w.Header().Get("Location"), http.StatusSeeOther, "/posts/1")
+	}
+}
+
+func TestFindTemplateCache(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `base`,
+		"home/index/content.html": `index`,
+		"user/base.html":          `user`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	for _, key := range [][2]string{{"home", "index"}, {"home", "contact"}, {"user", "index"}, {"admin", "index"}} {
+		for i := 0; i < 2; i++ {
+			ct, cname, cok := findTemplate(key[0], key[1])
+
+			templatesMutex.RLock()
+			ut, uname, uok := resolveTemplate(key[0], key[1])
+			templatesMutex.RUnlock()
+
+			if ct != ut || cname != uname || cok != uok {
+				t.Errorf("%v: cached resolution %s differs from uncached %s", key, cname, uname)
+			}
+		}
+	}
+
+	if len(resolvedTemplates) != 4 {
+		t.Errorf("Expected 4 cached resolutions, got %d", len(resolvedTemplates))
+	}
+
+	if err := parseViews(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resolvedTemplates) != 0 {
+		t.Errorf("Expected the cache to be reset when reparsing, got %d entries", len(resolvedTemplates))
+	}
+}
+
+func BenchmarkFindTemplate(b *testing.B) {
+	root := setupTestViews(map[string]string{
+		"base.html": `base`,
+	}, b)
+
+	defer os.RemoveAll(root)
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			findTemplate("home", "index")
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			templatesMutex.RLock()
+			resolveTemplate("home", "index")
+			templatesMutex.RUnlock()
+		}
+	})
+}
+
+func TestCreated(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/posts", nil)
+
+	c, w := recordingController("post", r)
+
+	c.Created("/posts/1")
+
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/posts/1" || w.Body.Len() != 0 {
+		t.Errorf("Result was %d to '%s', expected %d to '%s'", w.Code, w.Header().Get("Location"), http.StatusCreated, "/posts/1")
+	}
+
+	c, w = recordingController("post", r)
+
+	c.CreatedJSON("/posts/2", map[string]int{"id": 2})
+
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/posts/2" || w.Body.String() != "{\"id\":2}\n" {
+		t.Errorf("Result was %d to '%s' '%s', expected %d to '%s'", w.Code, w.Header().Get("Location"), w.Body.String(), http.StatusCreated, "/posts/2")
+	}
+}
+
+func TestGetIntSliceDefault(t *testing.T) {
+	def := []int64{7, 8}
+
+	type testCase struct {
+		url      string
+		expected []int64
+	}
+
+	testCases := []testCase{
+		testCase{"/?id=1&id=x&id=3", []int64{1, 3}},
+		testCase{"/?id=x&id=y", def},
+		testCase{"/", def},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c := NewController(&mockResponseWriter{}, r, "home")
+
+		result := c.GetIntSliceDefault("id", def)
+
+		if fmt.Sprint(result) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: result was %v, expected %v", tc.url, result, tc.expected)
+		}
+	}
+}
+
+func TestGetIndexedSlice(t *testing.T) {
+	type testCase struct {
+		url      string
+		expected []string
+	}
+
+	testCases := []testCase{
+		testCase{"/?filters[1]=b&filters[0]=a&filters[2]=c", []string{"a", "b", "c"}},
+		testCase{"/?filters%5B0%5D=a&filters%5B1%5D=b", []string{"a", "b"}},
+		testCase{"/?filters[0]=a&filters[1]=b&filters[3]=d", []string{"a", "b"}},
+		testCase{"/?filters[1]=b", nil},
+		testCase{"/", nil},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c := NewController(&mockResponseWriter{}, r, "home")
+
+		result := c.GetIndexedSlice("filters")
+
+		if fmt.Sprint(result) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: result was %v, expected %v", tc.url, result, tc.expected)
+		}
+	}
+}
+
+func TestBagGetters(t *testing.T) {
+	c := mockController("home")
+
+	c.ViewBag["title"] = "Home"
+	c.ViewBag["count"] = 3
+	c.ViewBag["admin"] = true
+
+	type testCase struct {
+		name             string
+		result, expected interface{}
+	}
+
+	testCases := []testCase{
+		testCase{"string", c.BagString("title", "def"), "Home"},
+		testCase{"string wrong type", c.BagString("count", "def"), "def"},
+		testCase{"string absent", c.BagString("missing", "def"), "def"},
+		testCase{"int", c.BagInt("count", -1), 3},
+		testCase{"int wrong type", c.BagInt("title", -1), -1},
+		testCase{"int absent", c.BagInt("missing", -1), -1},
+		testCase{"bool", c.BagBool("admin", false), true},
+		testCase{"bool wrong type", c.BagBool("title", false), false},
+		testCase{"bool absent", c.BagBool("missing", true), true},
+	}
+
+	for _, tc := range testCases {
+		if tc.result != tc.expected {
+			t.Errorf("%s: result was %v, expected %v", tc.name, tc.result, tc.expected)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	allowed := []string{"created", "name"}
+
+	type testCase struct {
+		url      string
+		expected []SortField
+		err      bool
+	}
+
+	testCases := []testCase{
+		testCase{"/?sort=-created,name", []SortField{SortField{"created", true}, SortField{"name", false}}, false},
+		testCase{"/?sort=name", []SortField{SortField{"name", false}}, false},
+		testCase{"/", nil, false},
+		testCase{"/?sort=-created,password", nil, true},
+		testCase{"/?sort=name,", nil, true},
+		testCase{"/?sort=-", nil, true},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c := NewController(&mockResponseWriter{}, r, "home")
+
+		result, err := c.ParseSort("sort", allowed)
+
+		if (err != nil) != tc.err || fmt.Sprint(result) != fmt.Sprint(tc.expected) {
+			t.Errorf("%s: result was %v with error %v, expected %v", tc.url, result, err, tc.expected)
+		}
+	}
+}
+
+func TestMergeBag(t *testing.T) {
+	type testCase struct {
+		overwrite bool
+		expected  string
+	}
+
+	testCases := []testCase{
+		testCase{true, "map[layout:wide title:Child user:matt]"},
+		testCase{false, "map[layout:wide title:Parent user:matt]"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("home")
+
+		c.ViewBag["title"] = "Parent"
+		c.ViewBag["user"] = "matt"
+
+		c.MergeBag(map[string]interface{}{"title": "Child", "layout": "wide"}, tc.overwrite)
+
+		if result := fmt.Sprint(c.ViewBag); result != tc.expected {
+			t.Errorf("Overwrite %v: bag was %s, expected %s", tc.overwrite, result, tc.expected)
+		}
+	}
+}
+
+func TestRenderStreaming(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `<ul>{{range .Model}}<li>{{.}}</li>{{end}}</ul>{{template "content.html" .}}`,
+		"home/index/content.html": `<p>{{.Name}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	model := []string{"a", "b", "<c>"}
+
+	buffered := mockController("home")
+
+	buffered.RenderViewModel("index", model)
+
+	streamed := mockController("home")
+
+	streamed.RenderStreaming("index", model)
+
+	b, s := buffered.ResponseWriter.(*mockResponseWriter).Body(), streamed.ResponseWriter.(*mockResponseWriter).Body()
+
+	if len(s) == 0 || !bytes.Equal(b, s) {
+		t.Errorf("Streamed result was '%s', expected '%s'", s, b)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":                     `base`,
+		"home/index/content.html":       `index`,
+		"home/contact/content.html":     `contact`,
+		"admin/users/list/content.html": `list`,
+		"user/base.html":                `user`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	if err := WarmUp(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"home/index", "home/contact", "admin/users", "admin/users/list"} {
+		if _, ok := resolvedTemplates[key]; !ok {
+			t.Errorf("Expected the templates for %s to be cached, cached %v", key, resolvedTemplates)
+		}
+	}
+
+	if len(resolvedTemplates) != 4 {
+		t.Errorf("Expected 4 cached resolutions, got %d", len(resolvedTemplates))
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("api", r)
+
+	c.JsonContent(map[string]string{"name": "matt"})
+
+	if contentLength := w.Header().Get("Content-Length"); contentLength != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("Content-Length was '%s', expected '%d'", contentLength, w.Body.Len())
+	}
+
+	c, w = recordingController("home", r)
+
+	c.TextContent("hello")
+
+	if contentLength := w.Header().Get("Content-Length"); contentLength != "5" {
+		t.Errorf("Content-Length was '%s', expected '5'", contentLength)
+	}
+
+	c, w = recordingController("home", r)
+
+	c.RenderStreaming("index", "streamed")
+
+	if contentLength := w.Header().Get("Content-Length"); contentLength != "" {
+		t.Errorf("Content-Length was '%s', expected none for a streamed response", contentLength)
+	}
+}
+
+func TestBytesContent(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("file", r)
+
+	body := []byte("<html><script>alert(1)</script>")
+
+	if err := c.BytesContent("image/png", body); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("Content-Type was '%s', expected 'image/png'", contentType)
+	}
+
+	if nosniff := w.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+		t.Errorf("X-Content-Type-Options was '%s', expected 'nosniff'", nosniff)
+	}
+
+	if !bytes.Equal(w.Body.Bytes(), body) {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.Bytes(), body)
+	}
+
+	c, w = recordingController("file", r)
+
+	if err := c.BytesContent("", body); err == nil || w.Body.Len() != 0 || len(w.Header()) != 0 {
+		t.Errorf("Expected an error without writing to the response for an empty content type")
+	}
+}
+
+func TestJsonContentFields(t *testing.T) {
+	type user struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email,omitempty"`
+	}
+
+	type testCase struct {
+		model    interface{}
+		fields   []string
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{user{1, "<matt>", "m@example.com"}, []string{"id"}, `{"id":1}`},
+		testCase{user{1, "<matt>", ""}, []string{"name", "email", "missing"}, `{"name":"\u003cmatt\u003e"}`},
+		testCase{user{1, "matt", ""}, nil, `{"id":1,"name":"matt"}`},
+		testCase{[]int{1, 2}, []string{"id"}, `[1,2]`},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("api")
+
+		c.JsonContentFields(tc.model, tc.fields)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected+"\n" {
+			t.Errorf("%v %v: result was '%s', expected '%s'", tc.model, tc.fields, body, tc.expected)
+		}
+	}
+}
+
+func TestJsonCollection(t *testing.T) {
+	var nilOrders []string
+
+	type testCase struct {
+		data     interface{}
+		meta     map[string]interface{}
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{[]string{"a", "b"}, map[string]interface{}{"total": 12, "page": 2}, `{"data":["a","b"],"meta":{"page":2,"total":12}}`},
+		testCase{[]string{}, map[string]interface{}{"total": 0}, `{"data":[],"meta":{"total":0}}`},
+		testCase{nilOrders, nil, `{"data":[],"meta":{}}`},
+		testCase{nil, nil, `{"data":[],"meta":{}}`},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("api")
+
+		c.JsonCollection(tc.data, tc.meta)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected+"\n" {
+			t.Errorf("%v: result was '%s', expected '%s'", tc.data, body, tc.expected)
+		}
+	}
+}
+
+func TestJSONEscapeHTML(t *testing.T) {
+	model := map[string]string{"html": "<b>&</b>"}
+
+	c := mockController("api")
+
+	c.JsonContent(model)
+
+	if expected := "{\"html\":\"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"}\n"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+
+	SetJSONEscapeHTML(false)
+
+	defer SetJSONEscapeHTML(true)
+
+	c = mockController("api")
+
+	c.JsonContent(model)
+
+	if expected := "{\"html\":\"<b>&</b>\"}\n"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestRenderParts(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":                  `unused`,
+		"email/welcome/html.html":    `<p>Welcome {{.Model}}</p>`,
+		"email/welcome/text.html":    `Welcome {{.Model}}`,
+		"email/welcome/subject.html": `Hi`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("email")
+
+	parts, err := c.RenderParts("welcome", []string{"html.html", "text.html"}, "matt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parts) != 2 || string(parts[0]) != "<p>Welcome matt</p>" || string(parts[1]) != "Welcome matt" {
+		t.Errorf("Result was %q, expected [\"<p>Welcome matt</p>\" \"Welcome matt\"]", parts)
+	}
+
+	if _, err := c.RenderParts("welcome", []string{"missing.html"}, nil); err == nil {
+		t.Errorf("Expected an error for a missing part")
+	}
+
+	if len(c.ResponseWriter.(*mockResponseWriter).Body()) != 0 {
+		t.Errorf("Expected nothing to be written to the response")
+	}
+}
+
+func TestSharedTemplates(t *testing.T) {
+	SetSharedViewDir("shared")
+
+	defer SetSharedViewDir("")
+
+	root := setupTestViews(map[string]string{
+		"shared/header.html":      `<header>{{.Controller}}</header>`,
+		"shared/footer.html":      `<footer>shared</footer>`,
+		"home/index/base.html":    `{{template "header.html" .}}index{{template "footer.html" .}}`,
+		"blog/base.html":          `{{template "header.html" .}}blog{{template "footer.html" .}}`,
+		"blog/recent/footer.html": `<footer>recent</footer>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		controller, view, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"home", "index", "<header>home</header>index<footer>shared</footer>"},
+		testCase{"blog", "show", "<header>blog</header>blog<footer>shared</footer>"},
+		testCase{"blog", "recent", "<header>blog</header>blog<footer>recent</footer>"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController(tc.controller)
+
+		c.Render(tc.view)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%s/%s: result was '%s', expected '%s'", tc.controller, tc.view, body, tc.expected)
+		}
+	}
+
+	if _, _, ok := findTemplate("shared", "header"); ok {
+		t.Errorf("Expected the shared templates not to define a view")
+	}
+
+	if _, _, ok := findTemplate("missing", "index"); ok {
+		t.Errorf("Expected the shared templates alone not to define a view of the root folder")
+	}
+}
+
+func TestIgnoredDirs(t *testing.T) {
+	SetIgnoredDirs([]string{"_*", ".git"})
+
+	defer SetIgnoredDirs(nil)
+
+	root := setupTestViews(map[string]string{
+		"base.html":               `{{template "content.html" .}}`,
+		"home/index/content.html": `home`,
+		"_partials/content.html":  `partial`,
+		"_partials/nav/item.html": `item`,
+		".git/hooks/content.html": `hook`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	templatesMutex.RLock()
+	defer templatesMutex.RUnlock()
+
+	for dirname := range templates {
+		if strings.Contains(dirname, "_partials") || strings.Contains(dirname, ".git") {
+			t.Errorf("Expected no templates for the ignored directory %s", dirname)
+		}
+	}
+
+	if _, ok := templates["home/index"]; !ok {
+		t.Errorf("Expected templates for home/index, parsed %v", viewFiles)
+	}
+}
+
+func TestFallbackView(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"home/index/base.html":  `index`,
+		"blog/base.html":        `blog`,
+		"shared/soon/base.html": `{{.Name}} is coming soon`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("reports")
+
+	c.Render("yearly")
+
+	if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); !strings.Contains(body, "not found") {
+		t.Errorf("Result was '%s', expected an error without a fallback view", body)
+	}
+
+	SetFallbackView("shared", "soon")
+
+	defer SetFallbackView("", "")
+
+	type testCase struct {
+		controller, view, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"reports", "yearly", "yearly is coming soon"},
+		testCase{"home", "index", "index"},
+		testCase{"home", "missing", "missing is coming soon"},
+		testCase{"blog", "missing", "blog"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController(tc.controller)
+
+		c.Render(tc.view)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%s/%s: result was '%s', expected '%s'", tc.controller, tc.view, body, tc.expected)
+		}
+	}
+}
+
+func TestRenderWithoutBaseTemplate(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"api/orders/content.html": `{"orders":[{{range $i, $o := .Model}}{{if $i}},{{end}}{{$o}}{{end}}]}`,
+		"api/orders/item.html":    `unused`,
+		"home/index/base.html":    `<main>{{template "content.html" .}}</main>`,
+		"home/index/content.html": `index`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		controller, view string
+		model            interface{}
+		expected         string
+	}
+
+	testCases := []testCase{
+		testCase{"api", "orders", []int{1, 2}, `{"orders":[1,2]}`},
+		testCase{"home", "index", nil, "<main>index</main>"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController(tc.controller)
+
+		c.RenderViewModel(tc.view, tc.model)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%s/%s: result was '%s', expected '%s'", tc.controller, tc.view, body, tc.expected)
+		}
+	}
+}
+
+func TestRenderToBuffer(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":               `<main>{{template "content.html" .}}</main>`,
+		"home/index/content.html": `{{.Controller}}/{{.Action}}/{{.Name}}: {{.Model}}{{if nonce}}{{end}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	buf, err := RenderToBuffer("home", "welcome", "index", "<matt>")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "<main>home/welcome/index: &lt;matt&gt;</main>"; buf.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", buf.String(), expected)
+	}
+
+	if _, err := RenderToBuffer("missing", "index", "index", nil); err == nil {
+		t.Errorf("Expected an error for a view lacking its content template")
+	}
+}
+
+func TestBaseTemplateName(t *testing.T) {
+	SetBaseTemplateName("layout.html")
+
+	defer SetBaseTemplateName("")
+
+	root := setupTestViews(map[string]string{
+		"layout.html":             `<main>{{template "content.html" .}}</main>`,
+		"home/index/content.html": `index`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	c := mockController("home")
+
+	c.Render("index")
+
+	if expected := "<main>index</main>"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestGetIP(t *testing.T) {
+	def := netip.MustParseAddr("127.0.0.1")
+
+	type testCase struct {
+		url, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"/?ip=192.0.2.1", "192.0.2.1"},
+		testCase{"/?ip=2001:db8::1", "2001:db8::1"},
+		testCase{"/?ip=garbage", "127.0.0.1"},
+		testCase{"/", "127.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c := NewController(&mockResponseWriter{}, r, "home")
+
+		if ip := c.GetIP("ip", def); ip.String() != tc.expected {
+			t.Errorf("%s: result was %v, expected %v", tc.url, ip, tc.expected)
+		}
+	}
+}
+
+func TestGetUUID(t *testing.T) {
+	type testCase struct {
+		url, expected string
+		ok            bool
+	}
+
+	testCases := []testCase{
+		testCase{"/?id=123e4567-e89b-12d3-a456-426614174000", "123e4567-e89b-12d3-a456-426614174000", true},
+		testCase{"/?id=123E4567-E89B-12D3-A456-426614174000", "123e4567-e89b-12d3-a456-426614174000", true},
+		testCase{"/?id=123e4567e89b-12d3-a456-4266141740000", "", false},
+		testCase{"/?id=123e4567-e89b-12d3-a456-42661417400g", "", false},
+		testCase{"/", "", false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c := NewController(&mockResponseWriter{}, r, "home")
+
+		if uuid, ok := c.GetUUID("id"); uuid != tc.expected || ok != tc.ok {
+			t.Errorf("%s: result was '%s' %v, expected '%s' %v", tc.url, uuid, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestActiveClass(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<a class="{{activeClass "home" "index"}}">Home</a><a class="{{activeClass "post"}}">Posts</a>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		controller, view, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"home", "index", `<a class="active">Home</a><a class="">Posts</a>`},
+		testCase{"home", "about", `<a class="">Home</a><a class="">Posts</a>`},
+		testCase{"post", "show", `<a class="">Home</a><a class="active">Posts</a>`},
+	}
+
+	for _, tc := range testCases {
+		c := mockController(tc.controller)
+
+		c.Render(tc.view)
+
+		if string(c.ResponseWriter.(*mockResponseWriter).Body()) != tc.expected {
+			t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), tc.expected)
+		}
+	}
+
+	SetActiveClass("current")
+
+	defer SetActiveClass("active")
+
+	c := mockController("home")
+
+	c.Action = "index"
+
+	c.Render("landing")
+
+	if expected := `<a class="current">Home</a><a class="">Posts</a>`; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestEqQuery(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{if eqQuery "tab" "profile"}}profile{{else}}other{{end}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		url, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"/?tab=profile", "profile"},
+		testCase{"/?tab=settings", "other"},
+		testCase{"/?tab=", "other"},
+		testCase{"/", "other"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", tc.url, nil)
+
+		c, w := recordingController("user", r)
+
+		c.Render("index")
+
+		if w.Body.String() != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.url, w.Body.String(), tc.expected)
+		}
+	}
+}
+
+func TestViewTransformer(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<title>{{.Bag.title}}</title>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetViewTransformer(func(v *View) {
+		v.Bag["title"] = v.Controller
+	}, func(v *View) {
+		v.Bag["title"] = strings.ToUpper(v.Bag["title"].(string)) + " | Site"
+	})
+
+	defer ResetViewTransformers()
+
+	c := mockController("blog")
+
+	c.Render("index")
+
+	if expected := "<title>BLOG | Site</title>"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestViewTransformerRegistrations(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{.Bag.a}} {{.Bag.b}} {{.Bag.order}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetViewTransformer(func(v *View) {
+		v.Bag["a"] = "A"
+		v.Bag["order"] = "a"
+	})
+
+	SetViewTransformer(func(v *View) {
+		v.Bag["b"] = "B"
+		v.Bag["order"] = v.Bag["order"].(string) + "b"
+	})
+
+	defer ResetViewTransformers()
+
+	c := mockController("blog")
+
+	c.Render("index")
+
+	if expected := "A B ab"; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s'", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+
+	ResetViewTransformers()
+
+	c = mockController("blog")
+
+	c.Render("index")
+
+	if expected := "  "; string(c.ResponseWriter.(*mockResponseWriter).Body()) != expected {
+		t.Errorf("Result was '%s', expected '%s' once the transformers are reset", c.ResponseWriter.(*mockResponseWriter).Body(), expected)
+	}
+}
+
+func TestViewTitle(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<title>{{.Title}}</title>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		title    interface{}
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{"Orders", "<title>Orders</title>"},
+		testCase{nil, "<title></title>"},
+		testCase{42, "<title></title>"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("orders")
+
+		if tc.title != nil {
+			c.ViewBag["title"] = tc.title
+		}
+
+		c.Render("index")
+
+		if result := string(c.ResponseWriter.(*mockResponseWriter).Body()); result != tc.expected {
+			t.Errorf("Result was '%s', expected '%s'", result, tc.expected)
+		}
+	}
+}
+
+func TestRenderWithFuncs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"views/base.html":             {Data: []byte(`{{now.Year}}`)},
+		"views/home/index/empty.html": {Data: []byte(``)},
+	}
+
+	viewConfig = nil
+
+	defer func() { viewConfig = nil }()
+
+	err := SetupViewsWithConfig(SetupViewsConfig{
+		FS:   fsys,
+		Root: "views",
+		Funcs: template.FuncMap{
+			"now": func() time.Time { return time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC) },
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := mockController("home")
+
+	c.RenderWithFuncs("index", nil, template.FuncMap{
+		"now": func() time.Time { return time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC) },
+	})
+
+	if result, expected := string(c.ResponseWriter.(*mockResponseWriter).Body()), "2001"; result != expected {
+		t.Errorf("Result was '%s', expected '%s'", result, expected)
+	}
+
+	c = mockController("home")
+
+	c.Render("index")
+
+	if result, expected := string(c.ResponseWriter.(*mockResponseWriter).Body()), "1999"; result != expected {
+		t.Errorf("Result was '%s', expected '%s', as the parsed templates should be unaffected", result, expected)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue is a value of a header such as Accept, with its quality, or weight.
+type qualityValue struct {
+	value   string
+	quality float64
+}
+
+// parseQualityValues parses a header of comma separated values with optional quality
+// parameters, e.g. "text/html,application/json;q=0.9", ordered by descending quality.
+// Values of equal quality retain the order of the header.
+func parseQualityValues(header string) []qualityValue {
+	var values []qualityValue
+
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+
+		v := qualityValue{strings.ToLower(strings.TrimSpace(params[0])), 1}
+
+		if v.value == "" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					v.quality = q
+				}
+			}
+		}
+
+		values = append(values, v)
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].quality > values[j].quality
+	})
+
+	return values
+}
+
+// mediaTypeMatches returns whether a media range of an Accept header, e.g. "text/*", matches a media type.
+func mediaTypeMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+
+	return strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1])
+}
+
+// AcceptsJSON returns whether the request's Accept header prefers a json response to an html one.
+func (c *Controller) AcceptsJSON() bool {
+	for _, v := range parseQualityValues(c.Request.Header.Get("Accept")) {
+		if v.quality <= 0 {
+			continue
+		}
+
+		html, json := mediaTypeMatches(v.value, "text/html"), mediaTypeMatches(v.value, "application/json")
+
+		if html || json {
+			return json && !html
+		}
+	}
+
+	return false
+}
+
+// PreferredLanguage returns the language of those supported most preferred by the request's
+// Accept-Language header, e.g. "fr" of {"fr", "de"} for "en-US,fr;q=0.9", or the first language
+// supported if none are acceptable. A language range matches a supported language equal to it or
+// prefixed by it, e.g. "en" matches "en-GB", failing which a range is matched as if truncated to its
+// primary language, e.g. "en-US" matches "en".
+func (c *Controller) PreferredLanguage(supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+
+	values := parseQualityValues(c.Request.Header.Get("Accept-Language"))
+
+	for _, v := range values {
+		if v.quality <= 0 {
+			continue
+		}
+
+		if v.value == "*" {
+			return anyLanguage(values, supported)
+		}
+
+		if lang, ok := matchLanguage(v.value, supported); ok {
+			return lang
+		}
+
+		if i := strings.Index(v.value, "-"); i > 0 {
+			if lang, ok := matchLanguage(v.value[:i], supported); ok {
+				return lang
+			}
+		}
+	}
+
+	return supported[0]
+}
+
+// anyLanguage returns the first of the supported languages not rejected, with a quality of 0,
+// by any of the provided language ranges.
+func anyLanguage(values []qualityValue, supported []string) string {
+	for _, lang := range supported {
+		rejected := false
+
+		for _, v := range values {
+			if _, ok := matchLanguage(v.value, []string{lang}); ok && v.quality <= 0 {
+				rejected = true
+			}
+		}
+
+		if !rejected {
+			return lang
+		}
+	}
+
+	return supported[0]
+}
+
+// matchLanguage returns the first of the supported languages matching a language range.
+func matchLanguage(languageRange string, supported []string) (string, bool) {
+	for _, lang := range supported {
+		l := strings.ToLower(lang)
+
+		if l == languageRange || strings.HasPrefix(l, languageRange+"-") {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+// Negotiate responds with the provided model as json if the request's Accept header prefers
+// json, otherwise it renders the provided view with the model. As the response depends on
+// the Accept header, the Vary header is set accordingly.
+func (c *Controller) Negotiate(view string, model interface{}) {
+	c.AddVary("Accept")
+
+	if c.AcceptsJSON() {
+		c.JsonContent(model)
+		return
+	}
+
+	c.RenderViewModel(view, model)
+}
+
+// AddVary adds a field to the response's Vary header, unless already present.
+func (c *Controller) AddVary(field string) {
+	header := c.ResponseWriter.Header()
+
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+
+	header.Add("Vary", field)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	type testCase struct {
+		accept, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"text/html,application/xhtml+xml,*/*;q=0.8", "<p>hello</p>"},
+		testCase{"application/json", "\"hello\"\n"},
+		testCase{"text/html;q=0.5, application/json", "\"hello\"\n"},
+		testCase{"", "<p>hello</p>"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tc.accept)
+
+		c, w := recordingController("home", r)
+
+		c.Negotiate("index", "hello")
+
+		if w.Body.String() != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.accept, w.Body.String(), tc.expected)
+		}
+
+		if strings.Join(w.Header().Values("Vary"), ",") != "Accept" {
+			t.Errorf("%s: Vary was %v, expected [Accept]", tc.accept, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.AddVary("Accept")
+	c.AddVary("Accept-Encoding")
+	c.AddVary("accept")
+
+	if strings.Join(w.Header().Values("Vary"), ",") != "Accept,Accept-Encoding" {
+		t.Errorf("Vary was %v, expected [Accept Accept-Encoding]", w.Header().Values("Vary"))
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	type testCase struct {
+		acceptLanguage string
+		supported      []string
+		expected       string
+	}
+
+	testCases := []testCase{
+		testCase{"en-US,fr;q=0.9", []string{"fr", "de"}, "fr"},
+		testCase{"en-US,fr;q=0.9", []string{"fr", "en"}, "en"},
+		testCase{"de;q=0.5, fr-CA;q=0.8", []string{"de", "fr"}, "fr"},
+		testCase{"en", []string{"fr", "en-GB"}, "en-GB"},
+		testCase{"FR-ca", []string{"de", "fr-CA"}, "fr-CA"},
+		testCase{"fr;q=0, *", []string{"fr", "de"}, "de"},
+		testCase{"es", []string{"fr", "de"}, "fr"},
+		testCase{"", []string{"de", "fr"}, "de"},
+		testCase{"en", nil, ""},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", tc.acceptLanguage)
+
+		c, _ := recordingController("home", r)
+
+		if result := c.PreferredLanguage(tc.supported); result != tc.expected {
+			t.Errorf("%s %v: result was '%s', expected '%s'", tc.acceptLanguage, tc.supported, result, tc.expected)
+		}
+	}
+}
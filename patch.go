@@ -0,0 +1,90 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ApplyMergePatch applies a json merge patch, as defined by RFC 7386, to the json encoding of
+// original, returning the patched json, e.g. for a PATCH endpoint to decode over its model.
+// Members of the patch replace those of the original, with a null member deleting the original's
+// member of the same name, and objects merged recursively. A patch which is not an object
+// replaces the original entirely.
+func ApplyMergePatch(original interface{}, patch []byte) ([]byte, error) {
+	b, err := json.Marshal(original)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := decodeJSONValue(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decodeJSONValue(patch)
+
+	if err != nil {
+		return nil, fmt.Errorf("The merge patch is invalid: %v", err)
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+// decodeJSONValue decodes json, preserving the representation of numbers.
+func decodeJSONValue(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	dec.UseNumber()
+
+	var v interface{}
+
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// mergePatch applies a decoded merge patch to a decoded target, as per RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+
+	return t
+}
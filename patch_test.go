@@ -0,0 +1,59 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import "testing"
+
+type testProfile struct {
+	Name    string            `json:"name"`
+	Email   string            `json:"email,omitempty"`
+	Age     int               `json:"age"`
+	Address map[string]string `json:"address,omitempty"`
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	original := testProfile{"matt", "matt@example.com", 30, map[string]string{"city": "London", "street": "High St"}}
+
+	type testCase struct {
+		name, patch, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"set", `{"name":"matthew","age":31}`, `{"address":{"city":"London","street":"High St"},"age":31,"email":"matt@example.com","name":"matthew"}`},
+		testCase{"delete", `{"email":null}`, `{"address":{"city":"London","street":"High St"},"age":30,"name":"matt"}`},
+		testCase{"nested merge", `{"address":{"city":"Paris","street":null,"zip":"75001"}}`, `{"address":{"city":"Paris","zip":"75001"},"age":30,"email":"matt@example.com","name":"matt"}`},
+		testCase{"replace object", `{"address":"unknown"}`, `{"address":"unknown","age":30,"email":"matt@example.com","name":"matt"}`},
+		testCase{"non-object patch", `["a"]`, `["a"]`},
+		testCase{"delete missing", `{"phone":null}`, `{"address":{"city":"London","street":"High St"},"age":30,"email":"matt@example.com","name":"matt"}`},
+	}
+
+	for _, tc := range testCases {
+		result, err := ApplyMergePatch(original, []byte(tc.patch))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(result) != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.name, result, tc.expected)
+		}
+	}
+
+	if _, err := ApplyMergePatch(original, []byte(`{"name":`)); err == nil {
+		t.Errorf("Expected an error for an invalid patch")
+	}
+}
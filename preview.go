@@ -0,0 +1,54 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import "sync"
+
+var (
+	viewModelSamples      = make(map[string]interface{})
+	viewModelSamplesMutex sync.RWMutex
+)
+
+// RegisterViewModel registers a sample of the model a view expects, keyed by the view and the
+// name of its controller, as Go templates do not declare the type of their data. The sample is
+// used to render the view via RenderPreview, e.g. from an admin tool previewing views.
+func RegisterViewModel(controller, view string, sample interface{}) {
+	viewModelSamplesMutex.Lock()
+	defer viewModelSamplesMutex.Unlock()
+
+	viewModelSamples[controller+"/"+view] = sample
+}
+
+// ViewModelSample returns the sample model registered for a view via RegisterViewModel, and
+// whether one was registered.
+func ViewModelSample(controller, view string) (interface{}, bool) {
+	viewModelSamplesMutex.RLock()
+	defer viewModelSamplesMutex.RUnlock()
+
+	sample, ok := viewModelSamples[controller+"/"+view]
+
+	return sample, ok
+}
+
+// RenderPreview renders a view of the named controller, which need not be the controller
+// rendering it, with the sample model registered via RegisterViewModel, or a nil model if none
+// is registered.
+func (c *Controller) RenderPreview(controller, view string) {
+	sample, _ := ViewModelSample(controller, view)
+
+	render(c, controller, view, c.newView(controller, view, sample))
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"os"
+	"testing"
+)
+
+type testInvoice struct {
+	Number string
+	Total  float64
+}
+
+func TestRenderPreview(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":                 `unused`,
+		"billing/invoice/base.html": `{{.Controller}}/{{.Name}}: {{with .Model}}{{.Number}} {{printf "%.2f" .Total}}{{else}}no model{{end}}`,
+		"billing/receipt/base.html": `{{.Controller}}/{{.Name}}: {{with .Model}}{{.}}{{else}}no model{{end}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	RegisterViewModel("billing", "invoice", testInvoice{"INV-1", 9.5})
+
+	defer delete(viewModelSamples, "billing/invoice")
+
+	if sample, ok := ViewModelSample("billing", "invoice"); !ok || sample.(testInvoice).Number != "INV-1" {
+		t.Errorf("Sample was %v, expected the registered sample", sample)
+	}
+
+	type testCase struct {
+		view, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"invoice", "billing/invoice: INV-1 9.50"},
+		testCase{"receipt", "billing/receipt: no model"},
+	}
+
+	for _, tc := range testCases {
+		c := mockController("admin")
+
+		c.RenderPreview("billing", tc.view)
+
+		if body := string(c.ResponseWriter.(*mockResponseWriter).Body()); body != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.view, body, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies are the address ranges of proxies whose forwarding headers are honored.
+var trustedProxies []netip.Prefix
+
+// SetTrustedProxies sets the address ranges, in CIDR notation or as single addresses, of the
+// proxies whose forwarding headers, e.g. X-Forwarded-For, are honored. Forwarding headers of
+// requests from any other address are ignored, as they can be spoofed by the client.
+func SetTrustedProxies(cidrs []string) error {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		var prefix netip.Prefix
+
+		addr, err := netip.ParseAddr(cidr)
+
+		if err == nil {
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		} else if prefix, err = netip.ParsePrefix(cidr); err != nil {
+			return err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	trustedProxies = prefixes
+
+	return nil
+}
+
+// isTrustedProxy returns whether the provided address is within the trusted proxy ranges.
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the address of the request's immediate peer.
+func (c *Controller) remoteIP() string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+
+	return host
+}
+
+// ClientIP returns the address of the client which made the request. If the request was made
+// by a trusted proxy, as set via SetTrustedProxies, the X-Forwarded-For header is consulted,
+// the client being the last address not itself a trusted proxy.
+func (c *Controller) ClientIP() string {
+	ip := c.remoteIP()
+
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(c.Request.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+
+		if hop == "" {
+			continue
+		}
+
+		ip = hop
+
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return ip
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetTrustedProxies(nil)
+
+	type testCase struct {
+		remoteAddr, forwardedFor, expected string
+	}
+
+	testCases := []testCase{
+		testCase{"10.1.2.3:5000", "203.0.113.7", "203.0.113.7"},
+		testCase{"10.1.2.3:5000", "198.51.100.1, 203.0.113.7, 192.168.1.1", "203.0.113.7"},
+		testCase{"192.168.1.1:5000", "", "192.168.1.1"},
+		testCase{"198.51.100.1:5000", "203.0.113.7", "198.51.100.1"},
+		testCase{"[2001:db8::1]:5000", "203.0.113.7", "2001:db8::1"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+
+		if tc.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+
+		c, _ := recordingController("home", r)
+
+		if ip := c.ClientIP(); ip != tc.expected {
+			t.Errorf("%v: result was '%s', expected '%s'", tc, ip, tc.expected)
+		}
+	}
+
+	if SetTrustedProxies([]string{"not a cidr"}) == nil {
+		t.Errorf("Expected an error for an invalid CIDR")
+	}
+}
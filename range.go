@@ -0,0 +1,136 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ByteRange is a range of bytes of a response's content, from Start to End inclusive.
+type ByteRange struct {
+	Start, End int64
+}
+
+// Length returns the number of bytes in the range.
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ParseRange parses the request's Range header against content of the provided size, returning
+// the range of the content to be served and the status to respond with:
+//
+//	http.StatusOK for requests without a Range header, or with multiple ranges, where the full content is served.
+//	http.StatusPartialContent for a satisfiable single range, with the Content-Range header set.
+//	http.StatusRequestedRangeNotSatisfiable for a malformed or unsatisfiable range.
+//
+// The Accept-Ranges header is set, advertising support for range requests.
+func (c *Controller) ParseRange(size int64) (ByteRange, int) {
+	header := c.ResponseWriter.Header()
+
+	header.Set("Accept-Ranges", "bytes")
+
+	full := ByteRange{0, size - 1}
+
+	spec := c.Request.Header.Get("Range")
+
+	if spec == "" {
+		return full, http.StatusOK
+	}
+
+	r, ok := parseByteRange(spec, size)
+
+	if !ok {
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+
+		return ByteRange{}, http.StatusRequestedRangeNotSatisfiable
+	}
+
+	if r == nil {
+		return full, http.StatusOK
+	}
+
+	header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, size))
+
+	return *r, http.StatusPartialContent
+}
+
+// parseByteRange parses a Range header specifying a single range, e.g. "bytes=0-499",
+// "bytes=500-" or "bytes=-500", against content of the provided size. A nil range is
+// returned for valid headers specifying multiple ranges, which are not supported.
+func parseByteRange(spec string, size int64) (*ByteRange, bool) {
+	if !strings.HasPrefix(spec, "bytes=") {
+		return nil, false
+	}
+
+	spec = strings.TrimSpace(spec[len("bytes="):])
+
+	if strings.Contains(spec, ",") {
+		return nil, true
+	}
+
+	dash := strings.Index(spec, "-")
+
+	if dash < 0 {
+		return nil, false
+	}
+
+	first, last := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+	var r ByteRange
+
+	if first == "" {
+		// a suffix range, e.g. "-500", requests the last bytes of the content
+		n, err := strconv.ParseInt(last, 10, 64)
+
+		if err != nil || n <= 0 || size == 0 {
+			return nil, false
+		}
+
+		if n > size {
+			n = size
+		}
+
+		r = ByteRange{size - n, size - 1}
+	} else {
+		start, err := strconv.ParseInt(first, 10, 64)
+
+		if err != nil || start < 0 || start >= size {
+			return nil, false
+		}
+
+		end := size - 1
+
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+
+			if err != nil || end < start {
+				return nil, false
+			}
+
+			if end >= size {
+				end = size - 1
+			}
+		}
+
+		r = ByteRange{start, end}
+	}
+
+	return &r, true
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	type testCase struct {
+		header       string
+		expected     ByteRange
+		status       int
+		contentRange string
+	}
+
+	testCases := []testCase{
+		testCase{"", ByteRange{0, 999}, http.StatusOK, ""},
+		testCase{"bytes=0-499", ByteRange{0, 499}, http.StatusPartialContent, "bytes 0-499/1000"},
+		testCase{"bytes=900-", ByteRange{900, 999}, http.StatusPartialContent, "bytes 900-999/1000"},
+		testCase{"bytes=-100", ByteRange{900, 999}, http.StatusPartialContent, "bytes 900-999/1000"},
+		testCase{"bytes=500-2000", ByteRange{500, 999}, http.StatusPartialContent, "bytes 500-999/1000"},
+		testCase{"bytes=0-1,5-9", ByteRange{0, 999}, http.StatusOK, ""},
+		testCase{"bytes=1000-", ByteRange{}, http.StatusRequestedRangeNotSatisfiable, "bytes */1000"},
+		testCase{"bytes=abc", ByteRange{}, http.StatusRequestedRangeNotSatisfiable, "bytes */1000"},
+		testCase{"items=0-1", ByteRange{}, http.StatusRequestedRangeNotSatisfiable, "bytes */1000"},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/download", nil)
+
+		if tc.header != "" {
+			r.Header.Set("Range", tc.header)
+		}
+
+		c, w := recordingController("file", r)
+
+		br, status := c.ParseRange(1000)
+
+		if br != tc.expected || status != tc.status || w.Header().Get("Content-Range") != tc.contentRange {
+			t.Errorf("%s: result was %v %d '%s', expected %v %d '%s'", tc.header, br, status, w.Header().Get("Content-Range"), tc.expected, tc.status, tc.contentRange)
+		}
+	}
+}
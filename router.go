@@ -0,0 +1,548 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is the signature of an action dispatched by a Router.
+type Action func(*Controller)
+
+// ErrorAction is the alternative signature of an action dispatched by a Router, for actions
+// which can fail. A returned error is passed to the Router's error handler.
+type ErrorAction func(*Controller) error
+
+// ErrorHandler is the signature of a function handling an error returned by an ErrorAction.
+type ErrorHandler func(c *Controller, err error)
+
+// Filter is the signature of a function run by a Router before dispatching to an action.
+// Returning false, or aborting the request via Controller.Abort, prevents any subsequent
+// filters and the action from running.
+type Filter func(*Controller) bool
+
+// Initializer is implemented by controllers registered via RegisterController which run setup
+// before each of their actions, e.g. loading the current user or beginning a transaction. The
+// Router calls Init after the filters have run; a returned error is passed to the Router's error
+// handler in place of running the action, as is aborting the request via Controller.Abort.
+type Initializer interface {
+	Init(c *Controller) error
+}
+
+// Finalizer is implemented by controllers registered via RegisterController which run cleanup
+// after each of their actions, e.g. committing or rolling back a transaction. The Router calls
+// Finalize once the action returns, whether or not it failed, and should it panic, before the
+// panic continues. Finalize is also called after an Init method which failed.
+type Finalizer interface {
+	Finalize(c *Controller)
+}
+
+// Router is an http.Handler which dispatches requests to the actions of registered controllers.
+type Router struct {
+	controllers  map[string]map[string]ErrorAction
+	initializers map[string]Initializer
+	finalizers   map[string]Finalizer
+	routes       []*route
+	filters      []Filter
+	middleware   []namedMiddleware
+	handler      http.Handler
+	errorHandler ErrorHandler
+
+	idempotencyStore IdempotencyStore
+	idempotencyScope func(c *Controller) string
+
+	spaView        string
+	spaAPIPrefixes []string
+
+	inFlight   sync.WaitGroup
+	drainMutex sync.Mutex
+	draining   bool
+}
+
+// route is an action registered for a method and path pattern.
+type route struct {
+	method     string
+	segments   []string
+	controller string
+	action     ErrorAction
+}
+
+// NewRouter can be used to instantiate a Router instance.
+func NewRouter() *Router {
+	rt := &Router{
+		controllers:  make(map[string]map[string]ErrorAction),
+		initializers: make(map[string]Initializer),
+		finalizers:   make(map[string]Finalizer),
+		errorHandler: (*Controller).HandleError,
+	}
+
+	rt.buildHandler()
+
+	return rt
+}
+
+// SetErrorHandler sets the function handling errors returned by actions. By default errors
+// are written via Controller.HandleError.
+func (rt *Router) SetErrorHandler(fn ErrorHandler) {
+	rt.errorHandler = fn
+}
+
+// withoutError adapts an Action to an ErrorAction which never fails.
+func (a Action) withoutError() ErrorAction {
+	return func(c *Controller) error {
+		a(c)
+		return nil
+	}
+}
+
+var (
+	actionType      = reflect.TypeOf(Action(nil))
+	errorActionType = reflect.TypeOf(ErrorAction(nil))
+)
+
+// RegisterController registers the actions of c under the provided controller name.
+// Every exported method of c with the signature func(*Controller) or func(*Controller) error
+// is registered as an action, named as the lowercased method name, e.g. the method Index is
+// dispatched to for the path "/[name]/index". Methods with any other signature are ignored.
+// The path "/[name]" is dispatched to the index action. If c implements Initializer or Finalizer,
+// its Init or Finalize method is not an action, but is called before or after every action
+// dispatched to for the controller name, those of routes registered via Handle with the name
+// included.
+func (rt *Router) RegisterController(name string, c interface{}) {
+	actions := make(map[string]ErrorAction)
+
+	initializer, isInitializer := c.(Initializer)
+
+	if isInitializer {
+		rt.initializers[name] = initializer
+	} else {
+		delete(rt.initializers, name)
+	}
+
+	finalizer, isFinalizer := c.(Finalizer)
+
+	if isFinalizer {
+		rt.finalizers[name] = finalizer
+	} else {
+		delete(rt.finalizers, name)
+	}
+
+	v := reflect.ValueOf(c)
+
+	for i := 0; i < v.NumMethod(); i++ {
+		method := v.Type().Method(i)
+
+		if method.PkgPath != "" || (isInitializer && method.Name == "Init") || (isFinalizer && method.Name == "Finalize") {
+			continue
+		}
+
+		actionName := strings.ToLower(method.Name)
+
+		switch t := v.Method(i).Type(); {
+		case t.ConvertibleTo(actionType):
+			actions[actionName] = v.Method(i).Convert(actionType).Interface().(Action).withoutError()
+		case t.ConvertibleTo(errorActionType):
+			actions[actionName] = v.Method(i).Convert(errorActionType).Interface().(ErrorAction)
+		}
+	}
+
+	rt.controllers[name] = actions
+}
+
+// Handle registers an action, rendering views of the named controller, to be dispatched to
+// for requests with the provided method and a path matching pattern. Segments of the
+// pattern of the form ":name" match any single path segment, and a final segment of the
+// form "*name" matches the remainder of the path, slashes included. The matched values are
+// available to the action via Controller.Param. GET routes also match HEAD requests. OPTIONS
+// requests for paths matching routes, or the actions of registered controllers, are answered with
+// a 204 No Content status and an Allow header listing the methods allowed, without dispatching
+// to an action, unless an OPTIONS route matches.
+// Routes registered via Handle take precedence over those of registered controllers.
+func (rt *Router) Handle(method, pattern, controller string, action Action) {
+	rt.HandleErr(method, pattern, controller, action.withoutError())
+}
+
+// HandleErr registers an action which can fail, as Handle does. An error returned by
+// the action is passed to the Router's error handler.
+func (rt *Router) HandleErr(method, pattern, controller string, action ErrorAction) {
+	rt.routes = append(rt.routes, &route{
+		method:     method,
+		segments:   strings.Split(strings.Trim(pattern, "/"), "/"),
+		controller: controller,
+		action:     action,
+	})
+}
+
+// match returns the path parameters captured if the route matches the escaped path.
+func (rt *route) match(escapedPath string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(escapedPath, "/"), "/")
+
+	params := make(map[string]string)
+
+	for i, s := range rt.segments {
+		if strings.HasPrefix(s, "*") && i == len(rt.segments)-1 {
+			if i >= len(segments) {
+				return nil, false
+			}
+
+			value, err := url.PathUnescape(strings.Join(segments[i:], "/"))
+
+			if err != nil {
+				return nil, false
+			}
+
+			params[s[1:]] = value
+
+			return params, true
+		}
+
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(s, ":") {
+			value, err := url.PathUnescape(segments[i])
+
+			if err != nil {
+				return nil, false
+			}
+
+			params[s[1:]] = value
+		} else if s != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, len(segments) == len(rt.segments)
+}
+
+// Before registers filters to be run, in the order registered, before every action.
+func (rt *Router) Before(filters ...Filter) {
+	rt.filters = append(rt.filters, filters...)
+}
+
+// ServeHTTP dispatches the request to the first matching route registered via Handle,
+// otherwise to the action of a registered controller matching the path "/[controller]/[action]",
+// running any middleware registered via Use around the dispatch.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := rt.handler
+
+	if accessLog == nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w}
+
+	r, fields := withLogFields(r)
+
+	start := time.Now()
+
+	h.ServeHTTP(rec, r)
+
+	logAccess(rec, r, fields, time.Since(start))
+}
+
+// serve dispatches the request as described by ServeHTTP.
+func (rt *Router) serve(w http.ResponseWriter, r *http.Request) {
+	if !rt.begin() {
+		w.Header().Set("Connection", "close")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	defer rt.inFlight.Done()
+
+	if r.Method == "OPTIONS" && !rt.handlesOptions(r) {
+		allowed := rt.allowedMethods(r)
+
+		if len(allowed) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	d, ok := rt.find(r)
+
+	if !ok && rt.spaFallbackApplies(r) {
+		d, ok = dispatch{"", "", rt.renderSPA, nil}, true
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if maxBodySize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	}
+
+	c := NewController(w, r, d.controller)
+
+	c.Action = d.actionName
+	c.params = d.params
+
+	c.RequestID()
+
+	if csrfEnabled && !c.VerifyCSRF() {
+		c.Abort(http.StatusForbidden, "Invalid CSRF token.")
+		return
+	}
+
+	for _, filter := range rt.filters {
+		if !filter(c) || c.Aborted() {
+			return
+		}
+	}
+
+	if rt.idempotencyStore != nil {
+		rt.dispatchIdempotent(c, d.action)
+		return
+	}
+
+	rt.dispatch(c, d.action)
+}
+
+// dispatch runs an action, after the Init method of the controller if it is an Initializer and
+// followed by its Finalize method if it is a Finalizer, passing any error returned to the error
+// handler.
+func (rt *Router) dispatch(c *Controller, action ErrorAction) {
+	if finalizer, ok := rt.finalizers[c.Name]; ok {
+		defer finalizer.Finalize(c)
+	}
+
+	if initializer, ok := rt.initializers[c.Name]; ok {
+		if err := initializer.Init(c); err != nil {
+			rt.errorHandler(c, err)
+			return
+		}
+
+		if c.Aborted() {
+			return
+		}
+	}
+
+	if err := action(c); err != nil {
+		rt.errorHandler(c, err)
+	}
+}
+
+var maxBodySize int64
+
+// SetMaxBodySize sets the maximum size, in bytes, of the bodies of requests served by a Router.
+// Reading beyond the limit fails with an *http.MaxBytesError, which Controller.HandleError
+// responds to with a 413 Request Entity Too Large status. Actions which do not read the
+// body are unaffected. A size of 0 removes the limit.
+func SetMaxBodySize(n int64) {
+	maxBodySize = n
+}
+
+// begin registers a request as in flight, unless the router is draining.
+func (rt *Router) begin() bool {
+	rt.drainMutex.Lock()
+	defer rt.drainMutex.Unlock()
+
+	if rt.draining {
+		return false
+	}
+
+	rt.inFlight.Add(1)
+
+	return true
+}
+
+// Drain causes the router to reject any new requests with a 503 Service Unavailable status,
+// in preparation for shutting down. Requests already in flight are unaffected.
+func (rt *Router) Drain() {
+	rt.drainMutex.Lock()
+	rt.draining = true
+	rt.drainMutex.Unlock()
+}
+
+// Wait blocks until all requests in flight have completed. Combined with Drain, it allows
+// the requests in flight to complete before shutting down, e.g.
+//
+//	router.Drain()
+//	router.Wait()
+//	server.Shutdown(ctx)
+func (rt *Router) Wait() {
+	rt.inFlight.Wait()
+}
+
+// controllerMethods are the methods allowed for the paths of registered controllers, whose
+// actions are dispatched to for any method.
+var controllerMethods = []string{"DELETE", "GET", "HEAD", "PATCH", "POST", "PUT"}
+
+// handlesOptions returns whether a route registered via Handle for the OPTIONS method matches
+// the request's path.
+func (rt *Router) handlesOptions(r *http.Request) bool {
+	for _, route := range rt.routes {
+		if route.method != "OPTIONS" {
+			continue
+		}
+
+		if _, ok := route.match(r.URL.EscapedPath()); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowedMethods returns the methods of the routes registered via Handle and the actions of
+// registered controllers matching the request's path, in alphabetical order, including HEAD for
+// GET routes and OPTIONS, which the Router answers for such paths unless a route handles OPTIONS
+// explicitly. Nil is returned if neither a route nor an action matches.
+func (rt *Router) allowedMethods(r *http.Request) []string {
+	methods := make(map[string]bool)
+
+	if _, ok := rt.findAction(r.URL.Path); ok {
+		for _, method := range controllerMethods {
+			methods[method] = true
+		}
+	}
+
+	for _, route := range rt.routes {
+		if _, ok := route.match(r.URL.EscapedPath()); ok {
+			methods[route.method] = true
+
+			if route.method == "GET" {
+				methods["HEAD"] = true
+			}
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil
+	}
+
+	methods["OPTIONS"] = true
+
+	allowed := make([]string, 0, len(methods))
+
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+
+	sort.Strings(allowed)
+
+	return allowed
+}
+
+// SPAFallback sets a view rendered, with a 200 status, for GET requests for html which do not
+// match any route, so that a single page application can handle routing client side, e.g. for a
+// page of the application being reloaded. The view is looked up as a view of the view root
+// directory, e.g. "app" for "[view root dir]/app". Requests for paths beginning with any of the
+// provided API prefixes, e.g. "/api/", or for assets, i.e. paths whose last segment has a file
+// extension, are not served the view, nor are requests which do not explicitly accept html.
+func (rt *Router) SPAFallback(indexView string, apiPrefixes ...string) {
+	rt.spaView = indexView
+	rt.spaAPIPrefixes = apiPrefixes
+}
+
+// spaFallbackApplies returns whether a request not matching any route is served the single
+// page application view set via SPAFallback.
+func (rt *Router) spaFallbackApplies(r *http.Request) bool {
+	if rt.spaView == "" || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+
+	for _, prefix := range rt.spaAPIPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+
+	if strings.Contains(path.Base(r.URL.Path), ".") {
+		return false
+	}
+
+	for _, v := range parseQualityValues(r.Header.Get("Accept")) {
+		if v.quality > 0 && v.value != "*/*" && mediaTypeMatches(v.value, "text/html") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderSPA renders the single page application view set via SPAFallback.
+func (rt *Router) renderSPA(c *Controller) error {
+	render(c, "", rt.spaView, c.newView("", rt.spaView, nil))
+
+	return nil
+}
+
+// dispatch describes the action a request is dispatched to.
+type dispatch struct {
+	controller string
+	actionName string
+	action     ErrorAction
+	params     map[string]string
+}
+
+// find returns the action the request is dispatched to.
+func (rt *Router) find(r *http.Request) (dispatch, bool) {
+	for _, route := range rt.routes {
+		if route.method != r.Method && !(route.method == "GET" && r.Method == "HEAD") {
+			continue
+		}
+
+		if params, ok := route.match(r.URL.EscapedPath()); ok {
+			return dispatch{route.controller, "", route.action, params}, true
+		}
+	}
+
+	return rt.findAction(r.URL.Path)
+}
+
+// findAction returns the action of a registered controller matching a path of the form
+// "/[controller]/[action]", or "/[controller]" for its index action.
+func (rt *Router) findAction(urlPath string) (dispatch, bool) {
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+
+	name, actionName := segments[0], "index"
+
+	if len(segments) > 2 {
+		return dispatch{}, false
+	}
+
+	if len(segments) == 2 {
+		actionName = segments[1]
+	}
+
+	action, ok := rt.controllers[name][actionName]
+
+	return dispatch{name, actionName, action, nil}, ok
+}
+
+// Param returns the value of the named path parameter, as matched by the route the request
+// was dispatched to, or an empty string if there is no such parameter.
+func (c *Controller) Param(name string) string {
+	return c.params[name]
+}
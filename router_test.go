@@ -0,0 +1,465 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPostController struct{}
+
+func (testPostController) Index(c *Controller) { c.TextContent("index of " + c.Name) }
+
+func (testPostController) Show(c *Controller) { c.TextContent("show " + c.GetString("id", "")) }
+
+func (testPostController) Publish(c *Controller) error {
+	c.WriteHeader(http.StatusConflict)
+	return errors.New("already published")
+}
+
+func (testPostController) Helper() string { return "not an action" }
+
+func (testPostController) hidden(c *Controller) { c.TextContent("hidden") }
+
+// testAccountController records the calls of its Init method and actions.
+type testAccountController struct {
+	calls []string
+}
+
+func (a *testAccountController) Init(c *Controller) error {
+	a.calls = append(a.calls, "init")
+
+	if c.GetString("user", "") == "" {
+		return NewHTTPError(http.StatusUnauthorized, "Sign in required.")
+	}
+
+	return nil
+}
+
+func (a *testAccountController) Index(c *Controller) {
+	a.calls = append(a.calls, "index")
+	c.TextContent("account of " + c.GetString("user", ""))
+}
+
+// testLedgerController records the calls of its actions and Finalize method.
+type testLedgerController struct {
+	calls []string
+}
+
+func (l *testLedgerController) Finalize(c *Controller) {
+	l.calls = append(l.calls, "finalize")
+}
+
+func (l *testLedgerController) Index(c *Controller) {
+	l.calls = append(l.calls, "index")
+	c.TextContent("ledger")
+}
+
+func (l *testLedgerController) Fail(c *Controller) error {
+	l.calls = append(l.calls, "fail")
+	return errors.New("insufficient funds")
+}
+
+func (l *testLedgerController) Panic(c *Controller) {
+	l.calls = append(l.calls, "panic")
+	panic("corrupt ledger")
+}
+
+func serveRouter(rt http.Handler, method, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+
+	r, _ := http.NewRequest(method, url, nil)
+
+	rt.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestRegisterController(t *testing.T) {
+	rt := NewRouter()
+
+	rt.RegisterController("post", testPostController{})
+
+	type testCase struct {
+		url, expected string
+		status        int
+	}
+
+	testCases := []testCase{
+		testCase{"/post/index", "index of post", http.StatusOK},
+		testCase{"/post", "index of post", http.StatusOK},
+		testCase{"/post/show?id=7", "show 7", http.StatusOK},
+		testCase{"/post/helper", "404 page not found\n", http.StatusNotFound},
+		testCase{"/post/hidden", "404 page not found\n", http.StatusNotFound},
+		testCase{"/user/index", "404 page not found\n", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		w := serveRouter(rt, "GET", tc.url)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d '%s', expected %d '%s'", tc.url, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+}
+
+func TestControllerInit(t *testing.T) {
+	rt := NewRouter()
+
+	account := &testAccountController{}
+
+	rt.RegisterController("account", account)
+
+	rt.Handle("GET", "/me", "account", func(c *Controller) {
+		account.calls = append(account.calls, "me")
+		c.TextContent("me")
+	})
+
+	type testCase struct {
+		url, expected string
+		status        int
+		calls         []string
+	}
+
+	testCases := []testCase{
+		testCase{"/account?user=matt", "account of matt", http.StatusOK, []string{"init", "index"}},
+		testCase{"/account", "Sign in required.\n", http.StatusUnauthorized, []string{"init"}},
+		testCase{"/me?user=matt", "me", http.StatusOK, []string{"init", "me"}},
+		testCase{"/me", "Sign in required.\n", http.StatusUnauthorized, []string{"init"}},
+		testCase{"/account/init?user=matt", "404 page not found\n", http.StatusNotFound, nil},
+	}
+
+	for _, tc := range testCases {
+		account.calls = nil
+
+		w := serveRouter(rt, "GET", tc.url)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected || !reflect.DeepEqual(account.calls, tc.calls) {
+			t.Errorf("%s: result was %d '%s' with calls %v, expected %d '%s' with calls %v", tc.url, w.Code, w.Body.String(), account.calls, tc.status, tc.expected, tc.calls)
+		}
+	}
+}
+
+func TestControllerFinalize(t *testing.T) {
+	rt := NewRouter()
+
+	ledger := &testLedgerController{}
+
+	rt.RegisterController("ledger", ledger)
+
+	type testCase struct {
+		url      string
+		status   int
+		calls    []string
+		panicked bool
+	}
+
+	testCases := []testCase{
+		testCase{"/ledger", http.StatusOK, []string{"index", "finalize"}, false},
+		testCase{"/ledger/fail", http.StatusInternalServerError, []string{"fail", "finalize"}, false},
+		testCase{"/ledger/panic", http.StatusOK, []string{"panic", "finalize"}, true},
+		testCase{"/ledger/finalize", http.StatusNotFound, nil, false},
+	}
+
+	for _, tc := range testCases {
+		ledger.calls = nil
+
+		var w *httptest.ResponseRecorder
+
+		panicked := func() (panicked bool) {
+			defer func() { panicked = recover() != nil }()
+
+			w = serveRouter(rt, "GET", tc.url)
+
+			return
+		}()
+
+		if panicked != tc.panicked || !reflect.DeepEqual(ledger.calls, tc.calls) {
+			t.Errorf("%s: calls were %v, panicked %v, expected %v, panicked %v", tc.url, ledger.calls, panicked, tc.calls, tc.panicked)
+		}
+
+		if !tc.panicked && w.Code != tc.status {
+			t.Errorf("%s: status was %d, expected %d", tc.url, w.Code, tc.status)
+		}
+	}
+}
+
+func TestFilterAbort(t *testing.T) {
+	rt := NewRouter()
+
+	executed := []string{}
+
+	rt.RegisterController("post", testPostController{})
+
+	rt.Before(func(c *Controller) bool {
+		executed = append(executed, "first")
+		c.Abort(http.StatusForbidden, "forbidden")
+		return true
+	}, func(c *Controller) bool {
+		executed = append(executed, "second")
+		return true
+	})
+
+	w := serveRouter(rt, "GET", "/post/index")
+
+	if w.Code != http.StatusForbidden || w.Body.String() != "forbidden\n" {
+		t.Errorf("Result was %d '%s', expected %d '%s'", w.Code, w.Body.String(), http.StatusForbidden, "forbidden\n")
+	}
+
+	if len(executed) != 1 {
+		t.Errorf("Expected only the aborting filter to run, ran %v", executed)
+	}
+}
+
+func TestHandleWildcard(t *testing.T) {
+	rt := NewRouter()
+
+	rt.Handle("GET", "/files/:owner/*path", "file", func(c *Controller) {
+		c.TextContent(c.Param("owner") + ":" + c.Param("path"))
+	})
+
+	type testCase struct {
+		url, expected string
+		status        int
+	}
+
+	testCases := []testCase{
+		testCase{"/files/matt/a/b/c.txt", "matt:a/b/c.txt", http.StatusOK},
+		testCase{"/files/matt/a%20b/c.txt", "matt:a b/c.txt", http.StatusOK},
+		testCase{"/files/matt", "404 page not found\n", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		w := serveRouter(rt, "GET", tc.url)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d '%s', expected %d '%s'", tc.url, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+
+	if w := serveRouter(rt, "POST", "/files/matt/a"); w.Code != http.StatusNotFound {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestErrorAction(t *testing.T) {
+	rt := NewRouter()
+
+	rt.HandleErr("GET", "/fail", "post", func(c *Controller) error {
+		return errors.New("database unavailable")
+	})
+
+	rt.RegisterController("post", testPostController{})
+
+	if w := serveRouter(rt, "GET", "/fail"); w.Code != http.StatusInternalServerError {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusInternalServerError)
+	}
+
+	var handled error
+
+	rt.SetErrorHandler(func(c *Controller, err error) {
+		handled = err
+		c.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if w := serveRouter(rt, "GET", "/fail"); w.Code != http.StatusServiceUnavailable || handled == nil || handled.Error() != "database unavailable" {
+		t.Errorf("Status was %d with error %v, expected %d", w.Code, handled, http.StatusServiceUnavailable)
+	}
+
+	handled = nil
+
+	if w := serveRouter(rt, "GET", "/post/publish"); w.Code != http.StatusConflict || handled == nil {
+		t.Errorf("Status was %d with error %v, expected %d", w.Code, handled, http.StatusConflict)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	rt := NewRouter()
+
+	started, release := make(chan bool), make(chan bool)
+
+	rt.Handle("GET", "/slow", "home", func(c *Controller) {
+		started <- true
+		<-release
+		c.TextContent("done")
+	})
+
+	slow := make(chan *httptest.ResponseRecorder)
+
+	go func() { slow <- serveRouter(rt, "GET", "/slow") }()
+
+	<-started
+
+	rt.Drain()
+
+	if w := serveRouter(rt, "GET", "/slow"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status was %d, expected %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	waited := make(chan bool)
+
+	go func() {
+		rt.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Errorf("Expected Wait to block while a request is in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+
+	if w := <-slow; w.Body.String() != "done" {
+		t.Errorf("Result was '%s', expected the in flight request to complete", w.Body.String())
+	}
+
+	<-waited
+}
+
+func TestMaxBodySize(t *testing.T) {
+	SetMaxBodySize(16)
+
+	defer SetMaxBodySize(0)
+
+	rt := NewRouter()
+
+	rt.HandleErr("POST", "/orders", "order", func(c *Controller) error {
+		var order map[string]string
+
+		if err := c.BindJSON(&order); err != nil {
+			return err
+		}
+
+		c.TextContent(order["product"])
+
+		return nil
+	})
+
+	rt.Handle("POST", "/ping", "order", func(c *Controller) { c.TextContent("pong") })
+
+	type testCase struct {
+		url, body, expected string
+		status              int
+	}
+
+	testCases := []testCase{
+		testCase{"/orders", `{"product":"a"}`, "a", http.StatusOK},
+		testCase{"/orders", `{"product":"` + strings.Repeat("a", 32) + `"}`, "Request Entity Too Large\n", http.StatusRequestEntityTooLarge},
+		testCase{"/ping", strings.Repeat("a", 32), "pong", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+
+		r, _ := http.NewRequest("POST", tc.url, strings.NewReader(tc.body))
+
+		rt.ServeHTTP(w, r)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d '%s', expected %d '%s'", tc.url, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":     `layout`,
+		"app/base.html": `<div id="app"></div>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	rt := NewRouter()
+
+	rt.Handle("GET", "/about", "home", func(c *Controller) { c.TextContent("about") })
+
+	rt.SPAFallback("app", "/api/")
+
+	type testCase struct {
+		method, url, accept, expected string
+		status                        int
+	}
+
+	html := "text/html,application/xhtml+xml,*/*;q=0.8"
+
+	testCases := []testCase{
+		testCase{"GET", "/some/deep/route", html, `<div id="app"></div>`, http.StatusOK},
+		testCase{"GET", "/about", html, "about", http.StatusOK},
+		testCase{"GET", "/api/foo", html, "404 page not found\n", http.StatusNotFound},
+		testCase{"GET", "/static/app.js", html, "404 page not found\n", http.StatusNotFound},
+		testCase{"GET", "/some/deep/route", "application/json", "404 page not found\n", http.StatusNotFound},
+		testCase{"GET", "/some/deep/route", "*/*", "404 page not found\n", http.StatusNotFound},
+		testCase{"POST", "/some/deep/route", html, "404 page not found\n", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+
+		r, _ := http.NewRequest(tc.method, tc.url, nil)
+		r.Header.Set("Accept", tc.accept)
+
+		rt.ServeHTTP(w, r)
+
+		if w.Code != tc.status || w.Body.String() != tc.expected {
+			t.Errorf("%s %s %s: result was %d '%s', expected %d '%s'", tc.method, tc.url, tc.accept, w.Code, w.Body.String(), tc.status, tc.expected)
+		}
+	}
+}
+
+func TestAutomaticOptions(t *testing.T) {
+	rt := NewRouter()
+
+	rt.Handle("GET", "/orders/:id", "order", func(c *Controller) { c.TextContent("show") })
+	rt.Handle("POST", "/orders/:id", "order", func(c *Controller) { c.TextContent("update") })
+	rt.Handle("DELETE", "/orders/:id/items", "order", func(c *Controller) {})
+	rt.Handle("OPTIONS", "/preflight", "cors", func(c *Controller) { c.TextContent("explicit") })
+	rt.Handle("GET", "/preflight", "cors", func(c *Controller) {})
+
+	rt.RegisterController("post", testPostController{})
+
+	type testCase struct {
+		url, allow, expected string
+		status               int
+	}
+
+	testCases := []testCase{
+		testCase{"/orders/7", "GET, HEAD, OPTIONS, POST", "", http.StatusNoContent},
+		testCase{"/orders/7/items", "DELETE, OPTIONS", "", http.StatusNoContent},
+		testCase{"/preflight", "", "explicit", http.StatusOK},
+		testCase{"/missing", "", "404 page not found\n", http.StatusNotFound},
+		testCase{"/post", "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT", "", http.StatusNoContent},
+		testCase{"/post/publish", "DELETE, GET, HEAD, OPTIONS, PATCH, POST, PUT", "", http.StatusNoContent},
+		testCase{"/post/missing", "", "404 page not found\n", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		w := serveRouter(rt, "OPTIONS", tc.url)
+
+		if w.Code != tc.status || w.Header().Get("Allow") != tc.allow || w.Body.String() != tc.expected {
+			t.Errorf("%s: result was %d '%s' with Allow '%s', expected %d '%s' with Allow '%s'", tc.url, w.Code, w.Body.String(), w.Header().Get("Allow"), tc.status, tc.expected, tc.allow)
+		}
+	}
+}
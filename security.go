@@ -0,0 +1,121 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Nonce returns a cryptographically random value, generated once per request.
+// The same value is returned by the "nonce" view template function, so that it
+// can be referenced by both the Content-Security-Policy header and the view.
+func (c *Controller) Nonce() string {
+	if c.nonce == "" {
+		b := make([]byte, 16)
+
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+
+		c.nonce = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	return c.nonce
+}
+
+// SetCSPNonceHeader sets a Content-Security-Policy header which only allows scripts
+// and styles carrying the request's nonce, e.g. <script nonce="{{nonce}}">.
+func (c *Controller) SetCSPNonceHeader() {
+	nonce := c.Nonce()
+
+	c.ResponseWriter.Header().Set("Content-Security-Policy",
+		fmt.Sprintf("script-src 'nonce-%s'; style-src 'nonce-%s'", nonce, nonce))
+}
+
+// SetSecurityHeaders sets headers providing a baseline of security: disabling MIME type sniffing,
+// disallowing framing of the response and limiting the referrer information sent. If csp is not
+// empty, it is set as the Content-Security-Policy header.
+func (c *Controller) SetSecurityHeaders(csp string) {
+	header := c.ResponseWriter.Header()
+
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+	if csp != "" {
+		header.Set("Content-Security-Policy", csp)
+	}
+}
+
+// IsSecure returns whether the request was made over TLS, either directly or, for requests made
+// by a trusted proxy as set via SetTrustedProxies, as indicated by the X-Forwarded-Proto header.
+func (c *Controller) IsSecure() bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+
+	return isTrustedProxy(c.remoteIP()) && strings.EqualFold(c.Request.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// RequireHTTPS returns whether the request was made over TLS, as per IsSecure. If not, the
+// client is redirected to the https equivalent of the requested URL and false returned.
+func (c *Controller) RequireHTTPS() bool {
+	if c.IsSecure() {
+		return true
+	}
+
+	u := *c.Request.URL
+
+	u.Scheme = "https"
+	u.Host = c.Request.Host
+
+	status := http.StatusMovedPermanently
+
+	if c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+		// preserves the method and body of the request, unlike a 301
+		status = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(c.ResponseWriter, c.Request, u.String(), status)
+
+	return false
+}
+
+// BearerToken returns the token of the request's "Authorization: Bearer" header, and whether the
+// header was present and well-formed. The scheme is matched case-insensitively; a header with an
+// empty token, or a token containing whitespace, is malformed.
+func (c *Controller) BearerToken() (string, bool) {
+	const scheme = "bearer "
+
+	auth := c.Request.Header.Get("Authorization")
+
+	if len(auth) < len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(auth[len(scheme):])
+
+	if token == "" || strings.ContainsAny(token, " \t") {
+		return "", false
+	}
+
+	return token, true
+}
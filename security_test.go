@@ -0,0 +1,167 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCSPNonce(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<script nonce="{{nonce}}"></script>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.SetCSPNonceHeader()
+	c.Render("index")
+
+	csp := w.Header().Get("Content-Security-Policy")
+
+	if !strings.Contains(csp, "'nonce-"+c.Nonce()+"'") {
+		t.Errorf("Header was '%s', expected it to reference nonce '%s'", csp, c.Nonce())
+	}
+
+	expected := `<script nonce="` + c.Nonce() + `"></script>`
+
+	if w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	other, _ := recordingController("home", r)
+
+	if other.Nonce() == c.Nonce() {
+		t.Errorf("Expected a distinct nonce per request")
+	}
+}
+
+func TestSetSecurityHeaders(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.SetSecurityHeaders("default-src 'self'")
+
+	expected := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Content-Security-Policy": "default-src 'self'",
+	}
+
+	for k, v := range expected {
+		if w.Header().Get(k) != v {
+			t.Errorf("%s was '%s', expected '%s'", k, w.Header().Get(k), v)
+		}
+	}
+
+	c, w = recordingController("home", r)
+
+	c.SetSecurityHeaders("")
+
+	if _, ok := w.Header()["Content-Security-Policy"]; ok {
+		t.Errorf("Expected no Content-Security-Policy header for an empty policy")
+	}
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer SetTrustedProxies(nil)
+
+	type testCase struct {
+		remoteAddr, forwardedProto string
+		tls                        bool
+		secure                     bool
+	}
+
+	testCases := []testCase{
+		testCase{"203.0.113.7:5000", "", true, true},
+		testCase{"10.0.0.1:5000", "https", false, true},
+		testCase{"203.0.113.7:5000", "https", false, false},
+		testCase{"203.0.113.7:5000", "", false, false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "http://example.com/account?tab=1", nil)
+		r.RemoteAddr = tc.remoteAddr
+
+		if tc.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		if tc.forwardedProto != "" {
+			r.Header.Set("X-Forwarded-Proto", tc.forwardedProto)
+		}
+
+		c, w := recordingController("account", r)
+
+		if secure := c.RequireHTTPS(); secure != tc.secure {
+			t.Errorf("%v: result was %v, expected %v", tc, secure, tc.secure)
+		}
+
+		if tc.secure {
+			continue
+		}
+
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "https://example.com/account?tab=1" {
+			t.Errorf("%v: result was %d to '%s', expected a redirect to https", tc, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	type testCase struct {
+		header   string
+		expected string
+		ok       bool
+	}
+
+	testCases := []testCase{
+		testCase{"Bearer abc.def-123", "abc.def-123", true},
+		testCase{"bearer abc", "abc", true},
+		testCase{"", "", false},
+		testCase{"Bearer ", "", false},
+		testCase{"Bearer", "", false},
+		testCase{"Bearer a b", "", false},
+		testCase{"Basic dXNlcjpwYXNz", "", false},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("GET", "/", nil)
+
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+
+		c, _ := recordingController("home", r)
+
+		if token, ok := c.BearerToken(); token != tc.expected || ok != tc.ok {
+			t.Errorf("%q: result was '%s', %v, expected '%s', %v", tc.header, token, ok, tc.expected, tc.ok)
+		}
+	}
+}
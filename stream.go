@@ -0,0 +1,120 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// StreamJSONResult writes the items received from the items channel to the response as they
+// arrive, as the elements of the json array "data" of an envelope also holding an "error",
+// i.e. {"data":[...],"error":null}. The stream ends when the items channel is closed, or when
+// an error is received from errCh, in which case the error's message is recorded as the
+// envelope's error, e.g. {"data":[1,2],"error":"timeout"}. An item which cannot be encoded
+// as json ends the stream likewise. A producer failing after sending some items should send
+// the error before closing the items channel. As the status is sent before the first item,
+// a failure part way through the stream is only reported via the envelope.
+// The stream is abandoned without reading any further items should the request's context
+// be cancelled, e.g. when the client disconnects, so producers should stop sending likewise.
+func (c *Controller) StreamJSONResult(items <-chan interface{}, errCh <-chan error) {
+	w := c.ResponseWriter
+
+	w.Header().Set("Content-Type", "application/javascript")
+
+	flusher, _ := w.(http.Flusher)
+
+	w.Write([]byte(`{"data":[`))
+
+	done := c.Request.Context().Done()
+
+	var streamErr error
+
+	first := true
+
+stream:
+	for streamErr == nil {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				// an error sent just before the items channel was closed may not have been received
+				select {
+				case streamErr = <-errCh:
+				default:
+				}
+
+				break stream
+			}
+
+			b, err := encodeJSON(item)
+
+			if err != nil {
+				streamErr = err
+				break stream
+			}
+
+			if !first {
+				w.Write([]byte(","))
+			}
+
+			first = false
+
+			w.Write(b)
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case err, ok := <-errCh:
+			if !ok {
+				// a closed error channel never fires
+				errCh = nil
+			}
+
+			streamErr = err
+		case <-done:
+			return
+		}
+	}
+
+	var errValue interface{}
+
+	if streamErr != nil {
+		errValue = streamErr.Error()
+	}
+
+	b, _ := encodeJSON(errValue)
+
+	w.Write([]byte(`],"error":`))
+	w.Write(b)
+	w.Write([]byte("}\n"))
+}
+
+// encodeJSON encodes a value as json, escaping html as set via SetJSONEscapeHTML.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	enc.SetEscapeHTML(jsonEscapeHTML)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStreamJSONResult(t *testing.T) {
+	type testCase struct {
+		name     string
+		items    []interface{}
+		err      error
+		expected string
+	}
+
+	testCases := []testCase{
+		testCase{"success", []interface{}{1, "<b>", map[string]int{"id": 3}}, nil, `{"data":[1,"\u003cb\u003e",{"id":3}],"error":null}` + "\n"},
+		testCase{"error", []interface{}{1, 2}, errors.New("timeout"), `{"data":[1,2],"error":"timeout"}` + "\n"},
+		testCase{"empty", nil, nil, `{"data":[],"error":null}` + "\n"},
+		testCase{"unencodable", []interface{}{1, func() {}}, nil, `{"data":[1],"error":"json: unsupported type: func()"}` + "\n"},
+	}
+
+	for _, tc := range testCases {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		r, _ := http.NewRequestWithContext(ctx, "GET", "/", nil)
+
+		c, w := recordingController("export", r)
+
+		items, errCh := make(chan interface{}), make(chan error)
+
+		go func() {
+			for _, item := range tc.items {
+				select {
+				case items <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if tc.err != nil {
+				errCh <- tc.err
+			}
+
+			close(items)
+		}()
+
+		c.StreamJSONResult(items, errCh)
+
+		cancel()
+
+		if w.Body.String() != tc.expected {
+			t.Errorf("%s: result was '%s', expected '%s'", tc.name, w.Body.String(), tc.expected)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	texttemplate "text/template"
+)
+
+// RenderTextWithDelims renders a view as plain text, with its templates parsed using the
+// provided action delimiters in place of "{{" and "}}", e.g. to generate a config file or
+// email which itself contains "{{". The templates of the view are looked up as by Render,
+// and are executed without html escaping. As all views are parsed at setup, templates which do
+// not parse with the default delimiters must have their delimiters set via SetViewDelims.
+func (c *Controller) RenderTextWithDelims(left, right, view string, model interface{}) {
+	if viewConfig != nil && viewConfig.DevMode {
+		if err := parseViews(); err != nil {
+			renderError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	_, name, ok := findTemplate(c.Name, view)
+
+	if !ok {
+		renderError(c, http.StatusInternalServerError, fmt.Errorf("The templates for %v were not found.", name))
+		return
+	}
+
+	templatesMutex.RLock()
+	files := viewFiles[name]
+	templatesMutex.RUnlock()
+
+	t, err := texttemplate.New(baseTemplateName()).
+		Delims(left, right).
+		Funcs(texttemplate.FuncMap(funcMap)).
+		Funcs(texttemplate.FuncMap(requestFuncMap(c))).
+		Funcs(texttemplate.FuncMap(viewConfig.Funcs)).
+		ParseFS(viewConfig.FS, files...)
+
+	if err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	entry := entryTemplateName(func(name string) bool {
+		found := t.Lookup(name)
+
+		return found != nil && found.Tree != nil
+	})
+
+	var buf bytes.Buffer
+
+	if err := executeTemplate(&buf, t, entry, c.newView(c.Name, view, model)); err != nil {
+		renderError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.writeText("text/plain", buf.String())
+}
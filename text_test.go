@@ -0,0 +1,79 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestRenderTextWithDelims(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html":              `unused`,
+		"config/nginx/base.html": `server_name <<.Model>>; # {{ .Host }} & <<upper "ok">>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("GET", "/config/nginx", nil)
+
+	c, w := recordingController("config", r)
+
+	c.RenderTextWithDelims("<<", ">>", "nginx", "example.com")
+
+	if expected := "server_name example.com; # {{ .Host }} & OK"; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/plain" {
+		t.Errorf("Content-Type was '%s', expected 'text/plain'", contentType)
+	}
+}
+
+func TestSetViewDelims(t *testing.T) {
+	SetViewDelims("greeting", "hello", "<<", ">>")
+
+	defer delete(viewDelims, "greeting/hello")
+
+	root := setupTestViews(map[string]string{
+		"base.html":                `{{template "content.html" .}}`,
+		"home/index/content.html":  `{{.Model}}`,
+		"greeting/hello/base.html": `Hello <<.Model>>, {{ user_name }} <<upper "ok">>`,
+		"greeting/hello/fr/x.html": `{{ prénom }}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	r, _ := http.NewRequest("GET", "/greeting/hello", nil)
+
+	c, w := recordingController("greeting", r)
+
+	c.RenderTextWithDelims("<<", ">>", "hello", "matt")
+
+	if expected := "Hello matt, {{ user_name }} OK"; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	c, w = recordingController("home", r)
+
+	c.RenderViewModel("index", "default delimiters")
+
+	if expected := "default delimiters"; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// renderResult is the outcome of rendering a view in the background.
+type renderResult struct {
+	c    *Controller
+	body []byte
+	err  error
+}
+
+// RenderWithTimeout has the same functionality as RenderViewModel, except that should rendering
+// the view take longer than the provided duration, the fallback function is called instead, e.g.
+// to write cached content. The view is rendered in the background on a copy of the controller,
+// whose request's context is cancelled once the duration elapses, so that template functions
+// performing slow operations, such as database queries, can be cancelled via the context.
+// The output of an abandoned render is discarded. The copy sets headers, e.g. via the "csrf" or
+// "requestID" template functions, on a copy of the response's headers, which are only set on the
+// response once the render completes in time. Should the client have gone, as per ClientGone, neither the
+// view is rendered nor the fallback called.
+func (c *Controller) RenderWithTimeout(view string, viewModel interface{}, d time.Duration, fallback func()) {
+	if c.ClientGone() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+
+	defer cancel()
+
+	v := c.newView(c.Name, view, viewModel)
+
+	rc := *c
+
+	rc.Request = c.Request.WithContext(ctx)
+
+	// the render works on its own headers, so that an abandoned render does not race with the
+	// fallback writing the response
+	rc.ResponseWriter = discardResponseWriter{c.ResponseWriter.Header().Clone()}
+
+	// buffered, so that an abandoned render does not block once it completes
+	done := make(chan renderResult, 1)
+
+	go func() {
+		body, err := renderBytes(&rc, rc.Name, view, v)
+
+		done <- renderResult{&rc, body, err}
+	}()
+
+	select {
+	case result := <-done:
+		// adopt the state the render established, e.g. its nonce and headers, on the original
+		// request and response
+		header := c.ResponseWriter.Header()
+
+		for k, values := range result.c.ResponseWriter.Header() {
+			header[k] = values
+		}
+
+		result.c.Request = c.Request
+		result.c.ResponseWriter = c.ResponseWriter
+		*c = *result.c
+
+		if result.err != nil {
+			renderError(c, http.StatusInternalServerError, result.err)
+			return
+		}
+
+		writeRendered(c, result.body)
+	case <-ctx.Done():
+		if c.ClientGone() {
+			return
+		}
+
+		fallback()
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testSlowModel is a view model whose Value method blocks until released.
+type testSlowModel struct {
+	release chan struct{}
+}
+
+func (m testSlowModel) Value() string {
+	<-m.release
+
+	return "slow"
+}
+
+func TestRenderWithTimeout(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model.Value}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	model := testSlowModel{make(chan struct{})}
+
+	defer close(model.release)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.RenderWithTimeout("index", model, 10*time.Millisecond, func() {
+		c.TextContent("fallback")
+	})
+
+	if expected := "fallback"; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+
+	fast := testSlowModel{make(chan struct{})}
+
+	close(fast.release)
+
+	c, w = recordingController("home", r)
+
+	c.RenderWithTimeout("index", fast, time.Second, func() {
+		t.Errorf("Expected the fallback not to be called")
+	})
+
+	if expected := "<p>slow</p>"; w.Body.String() != expected {
+		t.Errorf("Result was '%s', expected '%s'", w.Body.String(), expected)
+	}
+}
+
+func TestRenderWithTimeoutClientGone(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model.Value}}</p>`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	model := testSlowModel{make(chan struct{})}
+
+	close(model.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r.WithContext(ctx))
+
+	c.RenderWithTimeout("index", model, time.Second, func() {
+		t.Errorf("Expected the fallback not to be called")
+	})
+
+	if w.Body.Len() != 0 || c.renderDuration != 0 {
+		t.Errorf("Result was '%s', expected the render to be skipped", w.Body.String())
+	}
+}
+
+// testSignallingModel is a view model whose Value method blocks until released, and whose Done
+// method signals the end of the render.
+type testSignallingModel struct {
+	release, done chan struct{}
+}
+
+func (m testSignallingModel) Value() string {
+	<-m.release
+
+	return "slow"
+}
+
+func (m testSignallingModel) Done() string {
+	close(m.done)
+
+	return ""
+}
+
+func TestRenderWithTimeoutHeaders(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `<p>{{.Model.Value}} {{requestID}}</p>{{.Model.Done}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	model := testSignallingModel{make(chan struct{}), make(chan struct{})}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+
+	c, w := recordingController("home", r)
+
+	c.RenderWithTimeout("index", model, 10*time.Millisecond, func() {
+		// the abandoned render calls requestID, setting a header, while the fallback sets headers
+		close(model.release)
+
+		for i := 0; i < 100; i++ {
+			c.ResponseWriter.Header().Set("X-Fallback", strconv.Itoa(i))
+		}
+
+		c.TextContent("fallback")
+	})
+
+	<-model.done
+
+	if w.Body.String() != "fallback" || w.Header().Get("X-Request-ID") != "" {
+		t.Errorf("Result was '%s' with X-Request-ID '%s', expected the fallback without the abandoned render's headers", w.Body.String(), w.Header().Get("X-Request-ID"))
+	}
+
+	fast := testSignallingModel{make(chan struct{}), make(chan struct{})}
+
+	close(fast.release)
+
+	r.Header.Set("X-Request-ID", "abc-123")
+
+	c, w = recordingController("home", r)
+
+	c.RenderWithTimeout("index", fast, time.Second, func() {
+		t.Errorf("Expected the fallback not to be called")
+	})
+
+	if expected := "<p>slow abc-123</p>"; w.Body.String() != expected || w.Header().Get("X-Request-ID") != "abc-123" {
+		t.Errorf("Result was '%s' with X-Request-ID '%s', expected '%s' with X-Request-ID 'abc-123'", w.Body.String(), w.Header().Get("X-Request-ID"), expected)
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import "net/http"
+
+// DeclareTrailer declares the names of the trailers, headers sent after the body of the response,
+// to be set via SetTrailer, e.g. a checksum of a streamed body. Trailers must be declared before
+// anything is written to the response.
+func (c *Controller) DeclareTrailer(keys ...string) {
+	for _, key := range keys {
+		c.ResponseWriter.Header().Add("Trailer", key)
+	}
+}
+
+// SetTrailer sets a trailer of the response, sent after the body, once the action has written the
+// body. Trailers are only sent by responses to HTTP/1.1 and later requests, whose bodies are sent
+// in chunks, and are otherwise dropped, so clients must not rely on receiving them. As responses
+// with a Content-Length, e.g. those written by JsonContent, are not sent in chunks, trailers suit
+// bodies written directly to the response, or streamed, e.g. via RenderStreaming.
+func (c *Controller) SetTrailer(key, value string) {
+	if !c.Request.ProtoAtLeast(1, 1) {
+		return
+	}
+
+	c.ResponseWriter.Header().Set(http.TrailerPrefix+key, value)
+}
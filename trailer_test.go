@@ -0,0 +1,81 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrailers(t *testing.T) {
+	content := []byte("exported data")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := NewController(w, r, "export")
+
+		c.DeclareTrailer("X-Checksum")
+
+		h := sha256.New()
+
+		w.Write(content)
+		h.Write(content)
+
+		c.SetTrailer("X-Checksum", hex.EncodeToString(h.Sum(nil)))
+		c.SetTrailer("X-Undeclared", "set")
+	}))
+
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	sum := sha256.Sum256(content)
+
+	if string(body) != string(content) {
+		t.Errorf("Body was '%s', expected '%s'", body, content)
+	}
+
+	if checksum := resp.Trailer.Get("X-Checksum"); checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("Trailer was '%s', expected '%s'", checksum, hex.EncodeToString(sum[:]))
+	}
+
+	if undeclared := resp.Trailer.Get("X-Undeclared"); undeclared != "set" {
+		t.Errorf("Undeclared trailer was '%s', expected 'set'", undeclared)
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.ProtoMajor, r.ProtoMinor = 1, 0
+
+	c, w := recordingController("export", r)
+
+	c.SetTrailer("X-Checksum", "x")
+
+	if len(w.Header()) != 0 {
+		t.Errorf("Expected no trailer for an HTTP/1.0 request, got %v", w.Header())
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"io"
+	"net/http"
+)
+
+// UploadStore is a storage backend files uploaded to the application are saved to, e.g. a
+// directory or an object store.
+type UploadStore interface {
+	// Save stores the content read from r under, or derived from, the provided file name,
+	// returning an identifier of the stored file, e.g. its path or URL.
+	Save(name string, r io.Reader) (string, error)
+}
+
+// StreamUpload saves the first file uploaded via the provided field of a multipart form to the
+// provided store, returning the identifier returned by the store. Unlike GetUploadedFile, the
+// file is streamed from the request body to the store, without being held in memory or written
+// to a temporary file, so that large files can be uploaded. As the form is read as it streams,
+// parts preceding the file are skipped, and the form helpers, e.g. GetFormString, cannot also
+// be used for the request. An error is returned if the request is not a multipart form or no
+// file was uploaded via the field.
+func (c *Controller) StreamUpload(field string, store UploadStore) (string, error) {
+	mr, err := c.Request.MultipartReader()
+
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		part, err := mr.NextPart()
+
+		if err == io.EOF {
+			return "", http.ErrMissingFile
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if part.FormName() == field && part.FileName() != "" {
+			defer part.Close()
+
+			return store.Save(part.FileName(), part)
+		}
+
+		part.Close()
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testUploadStore is an UploadStore capturing the files saved to it.
+type testUploadStore struct {
+	files map[string][]byte
+}
+
+func (s *testUploadStore) Save(name string, r io.Reader) (string, error) {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	s.files[name] = b
+
+	return "uploads/" + name, nil
+}
+
+func TestStreamUpload(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+
+	var buf bytes.Buffer
+
+	mw := multipart.NewWriter(&buf)
+
+	mw.WriteField("title", "report")
+
+	fw, _ := mw.CreateFormFile("document", "../report.csv")
+
+	fw.Write([]byte(content))
+
+	mw.Close()
+
+	r, _ := http.NewRequest("POST", "/", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	c, _ := recordingController("upload", r)
+
+	store := &testUploadStore{make(map[string][]byte)}
+
+	id, err := c.StreamUpload("document", store)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "uploads/report.csv" || string(store.files["report.csv"]) != content {
+		t.Errorf("Saved %s with %d bytes, expected uploads/report.csv with %d bytes", id, len(store.files["report.csv"]), len(content))
+	}
+
+	r, _ = http.NewRequest("POST", "/", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	c, _ = recordingController("upload", r)
+
+	if _, err := c.StreamUpload("title", store); err != http.ErrMissingFile {
+		t.Errorf("Error was %v, expected %v", err, http.ErrMissingFile)
+	}
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader("title=report"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c, _ = recordingController("upload", r)
+
+	if _, err := c.StreamUpload("document", store); err != http.ErrNotMultipart {
+		t.Errorf("Error was %v, expected %v", err, http.ErrNotMultipart)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BindAndValidateJSON decodes the json request body into the struct pointed to by dst, then
+// validates its fields against the constraints of their "validate" tags, returning the
+// validation errors found keyed by the json name of each field. Constraints are comma
+// separated, of the following:
+//
+//	required  the field must not be its zero value, e.g. an empty string
+//	min=n     an int field must be at least n, a string field at least n characters long
+//	max=n     an int field must be at most n, a string field at most n characters long
+//
+// If binding fails, validation is skipped and the binding error returned.
+func (c *Controller) BindAndValidateJSON(dst interface{}) (ValidationErrors, error) {
+	if err := c.BindJSON(dst); err != nil {
+		return nil, err
+	}
+
+	return validateStruct(dst)
+}
+
+// validateStruct validates the fields of the struct pointed to by dst against the
+// constraints of their "validate" tags.
+func validateStruct(dst interface{}) (ValidationErrors, error) {
+	v := reflect.Indirect(reflect.ValueOf(dst))
+
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Cannot validate %T, a struct is required.", dst)
+	}
+
+	errs := ValidationErrors{}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		tag := field.Tag.Get("validate")
+
+		name := fieldName(field, "json")
+
+		if tag == "" || name == "" {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			msg, err := validateField(v.Field(i), strings.TrimSpace(rule))
+
+			if err != nil {
+				return nil, fmt.Errorf("Field %v: %v", field.Name, err)
+			}
+
+			if msg != "" {
+				errs[name] = msg
+				break
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// validateField checks a field against a single constraint, returning a description of why
+// the field is invalid, or an empty string if it is valid.
+func validateField(v reflect.Value, rule string) (string, error) {
+	if rule == "required" {
+		if v.IsZero() {
+			return "is required", nil
+		}
+
+		return "", nil
+	}
+
+	parts := strings.SplitN(rule, "=", 2)
+
+	if len(parts) != 2 || (parts[0] != "min" && parts[0] != "max") {
+		return "", fmt.Errorf("Unknown constraint %q.", rule)
+	}
+
+	limit, err := strconv.ParseInt(parts[1], 10, 64)
+
+	if err != nil {
+		return "", fmt.Errorf("Invalid constraint %q.", rule)
+	}
+
+	var n int64
+
+	unit := ""
+
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = int64(len([]rune(v.String()))), " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = v.Int()
+	default:
+		return "", fmt.Errorf("Constraint %q is not supported for type %v.", rule, v.Type())
+	}
+
+	if parts[0] == "min" && n < limit {
+		return fmt.Sprintf("must be at least %d%s", limit, unit), nil
+	}
+
+	if parts[0] == "max" && n > limit {
+		return fmt.Sprintf("must be at most %d%s", limit, unit), nil
+	}
+
+	return "", nil
+}
+
+// QueryValidator reads required URL query values of a request, collecting the errors of every
+// missing or invalid value rather than stopping at the first, e.g.
+//
+//	q := c.ValidateQuery()
+//	page, size, sort := q.RequireInt("page"), q.RequireInt("size"), q.RequireString("sort")
+//
+//	if errs := q.Errors(); len(errs) > 0 {
+//		...
+//	}
+type QueryValidator struct {
+	c    *Controller
+	errs ValidationErrors
+}
+
+// ValidateQuery returns a QueryValidator reading the request's URL query values.
+func (c *Controller) ValidateQuery() *QueryValidator {
+	return &QueryValidator{c: c, errs: ValidationErrors{}}
+}
+
+// require returns the value of the query parameter, recording an error if it has none.
+func (q *QueryValidator) require(queryParam string) (string, bool) {
+	s := q.c.GetString(queryParam, "")
+
+	if s == "" {
+		q.errs[queryParam] = "is required"
+		return "", false
+	}
+
+	return s, true
+}
+
+// RequireString returns the value of the query parameter, recording an error if it has none.
+func (q *QueryValidator) RequireString(queryParam string) string {
+	s, _ := q.require(queryParam)
+
+	return s
+}
+
+// RequireInt64 returns the value of the query parameter as an int64, recording an error if it
+// has none or the value is not parsable as an integer, in which case 0 is returned.
+func (q *QueryValidator) RequireInt64(queryParam string) int64 {
+	s, ok := q.require(queryParam)
+
+	if !ok {
+		return 0
+	}
+
+	i, err := strconv.ParseInt(s, 10, 64)
+
+	if err != nil {
+		q.errs[queryParam] = "must be an integer"
+		return 0
+	}
+
+	return i
+}
+
+// RequireInt returns the value of the query parameter as an int, as RequireInt64 does.
+func (q *QueryValidator) RequireInt(queryParam string) int {
+	return int(q.RequireInt64(queryParam))
+}
+
+// RequireBool returns the value of the query parameter as a bool, recording an error if it
+// has none or the value is not parsable as a bool, e.g. "true" or "0", in which case false is returned.
+func (q *QueryValidator) RequireBool(queryParam string) bool {
+	s, ok := q.require(queryParam)
+
+	if !ok {
+		return false
+	}
+
+	b, err := strconv.ParseBool(s)
+
+	if err != nil {
+		q.errs[queryParam] = "must be a boolean"
+		return false
+	}
+
+	return b
+}
+
+// Errors returns the errors recorded, keyed by query parameter.
+func (q *QueryValidator) Errors() ValidationErrors {
+	return q.errs
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type testOrder struct {
+	Product  string `json:"product" validate:"required,max=10"`
+	Quantity int    `json:"quantity" validate:"min=1,max=100"`
+	Note     string `json:"note"`
+}
+
+func TestBindAndValidateJSON(t *testing.T) {
+	type testCase struct {
+		body     string
+		expected ValidationErrors
+	}
+
+	testCases := []testCase{
+		testCase{`{"quantity": 5}`, ValidationErrors{"product": "is required"}},
+		testCase{`{"product": "pen", "quantity": 101}`, ValidationErrors{"quantity": "must be at most 100"}},
+		testCase{`{"product": "fountain pen", "quantity": 0}`, ValidationErrors{"product": "must be at most 10 characters", "quantity": "must be at least 1"}},
+		testCase{`{"product": "pen", "quantity": 5}`, ValidationErrors{}},
+	}
+
+	for _, tc := range testCases {
+		r, _ := http.NewRequest("POST", "/orders", strings.NewReader(tc.body))
+
+		c, _ := recordingController("order", r)
+
+		errs, err := c.BindAndValidateJSON(&testOrder{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if errs.Error() != tc.expected.Error() || len(errs) != len(tc.expected) {
+			t.Errorf("%s: errors were %v, expected %v", tc.body, errs, tc.expected)
+		}
+	}
+}
+
+func TestQueryValidator(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/reports?page=x&sort=name", nil)
+
+	c, _ := recordingController("report", r)
+
+	q := c.ValidateQuery()
+
+	page, size, sort := q.RequireInt("page"), q.RequireInt("size"), q.RequireString("sort")
+
+	if page != 0 || size != 0 || sort != "name" {
+		t.Errorf("Values were %d, %d, '%s', expected 0, 0, 'name'", page, size, sort)
+	}
+
+	expected := ValidationErrors{"page": "must be an integer", "size": "is required"}
+
+	if errs := q.Errors(); errs.Error() != expected.Error() || len(errs) != len(expected) {
+		t.Errorf("Errors were %v, expected %v", errs, expected)
+	}
+
+	r, _ = http.NewRequest("GET", "/reports?page=2&archived=true", nil)
+
+	c, _ = recordingController("report", r)
+
+	q = c.ValidateQuery()
+
+	if page, archived := q.RequireInt64("page"), q.RequireBool("archived"); page != 2 || !archived || len(q.Errors()) != 0 {
+		t.Errorf("Values were %d, %v with errors %v, expected 2, true without errors", page, archived, q.Errors())
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import "sync"
+
+var (
+	globalData      map[string]interface{}
+	globalDataMutex sync.RWMutex
+)
+
+var viewDataProviders []func(c *Controller) map[string]interface{}
+
+// SetGlobalViewData sets entries merged into the Bag of every View, e.g. the name of the site.
+// Entries set by view data providers, view transformers, the controller's ViewBag or
+// per-render data take precedence over them, in that increasing order.
+func SetGlobalViewData(data map[string]interface{}) {
+	copied := make(map[string]interface{}, len(data))
+
+	mergeInto(copied, data)
+
+	globalDataMutex.Lock()
+	globalData = copied
+	globalDataMutex.Unlock()
+}
+
+// globalViewData returns the entries set via SetGlobalViewData.
+func globalViewData() map[string]interface{} {
+	globalDataMutex.RLock()
+	defer globalDataMutex.RUnlock()
+
+	return globalData
+}
+
+// SetViewDataProviders sets functions, called in order, providing entries merged into the Bag of
+// every View rendered for a request, e.g. the signed in user. Entries of later providers take
+// precedence over those of earlier ones and the global view data, but not over those set by
+// view transformers, the controller's ViewBag or per-render data.
+func SetViewDataProviders(fns ...func(c *Controller) map[string]interface{}) {
+	viewDataProviders = fns
+}
+
+// RenderWithData has the same functionality as RenderViewModel, as well as the ability to pass
+// along data merged into the Bag of the View for this render only, taking precedence over the
+// entries of the controller's ViewBag, which is left unchanged.
+func (c *Controller) RenderWithData(view string, viewModel interface{}, data map[string]interface{}) {
+	v := c.newViewWithData(c.Name, view, viewModel, data)
+
+	render(c, c.Name, view, v)
+}
+
+// mergeInto copies the entries of src into dst, overwriting existing entries.
+func mergeInto(dst, src map[string]interface{}) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2013 Matt Stephanou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mvc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestViewDataPrecedence(t *testing.T) {
+	root := setupTestViews(map[string]string{
+		"base.html": `{{.Bag.global}} {{.Bag.provider}} {{.Bag.transformer}} {{.Bag.controller}} {{.Bag.render}}`,
+	}, t)
+
+	defer os.RemoveAll(root)
+
+	SetGlobalViewData(map[string]interface{}{
+		"global": "global", "provider": "global", "transformer": "global", "controller": "global", "render": "global",
+	})
+
+	defer SetGlobalViewData(nil)
+
+	SetViewDataProviders(func(c *Controller) map[string]interface{} {
+		return map[string]interface{}{"provider": "provider", "transformer": "provider", "controller": "provider", "render": "provider"}
+	})
+
+	defer SetViewDataProviders()
+
+	SetViewTransformer(func(v *View) {
+		for _, key := range []string{"transformer", "controller", "render"} {
+			v.Bag[key] = "transformer"
+		}
+	})
+
+	defer ResetViewTransformers()
+
+	c := mockController("home")
+
+	c.ViewBag["controller"] = "controller"
+	c.ViewBag["render"] = "controller"
+
+	c.RenderWithData("index", nil, map[string]interface{}{"render": "render"})
+
+	expected := "global provider transformer controller render"
+
+	if result := string(c.ResponseWriter.(*mockResponseWriter).Body()); result != expected {
+		t.Errorf("Result was '%s', expected '%s'", result, expected)
+	}
+
+	if c.ViewBag["render"] != "controller" || len(c.ViewBag) != 2 {
+		t.Errorf("Result was %v, expected the controller's ViewBag to be left unchanged", c.ViewBag)
+	}
+}